@@ -0,0 +1,276 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// requestGroup identifies a request of InverterValuesDef
+type requestGroup struct {
+	Object uint16 `json:"object"`
+	Start  uint32 `json:"start"`
+	End    uint32 `json:"end"`
+}
+
+// newRequestGroup of definition
+func newRequestGroup(def InverterValuesDef) requestGroup {
+	return requestGroup{
+		Object: def.Object,
+		Start:  def.Start,
+		End:    def.End,
+	}
+}
+
+// unsupportedStrikes is the number of consecutive 0x15 responses after
+// which a request is stored as unsupported
+const unsupportedStrikes = 2
+
+// capabilities of a single device
+type capabilities struct {
+	Supported   []requestGroup `json:"supported"`
+	Unsupported []requestGroup `json:"unsupported"`
+	// values returned by supported requests
+	Values []ValueID `json:"values"`
+}
+
+// containsValue returns true if id is part of ids
+func containsValue(ids []ValueID, id ValueID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+// contains returns true if group is part of groups
+func containsGroup(groups []requestGroup, group requestGroup) bool {
+	for _, g := range groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// CapabilityCache stores per serial number which requests are supported
+// by a device so unsupported requests are skipped on later polls.
+// Note: if a device gets new capabilities (e.g. firmware update) the cache
+// entry has to be removed with Forget.
+type CapabilityCache struct {
+	// path of file the cache is persisted in (empty for memory only)
+	path string
+
+	mutex   sync.RWMutex
+	devices map[uint32]*capabilities
+	// consecutive unsupported responses of requests not stored yet
+	strikes map[uint32]map[requestGroup]int
+	// true if devices changed since last save
+	dirty bool
+}
+
+// NewCapabilityCache creates a cache that is persisted to the file at path
+// If path is empty the cache is only kept in memory.
+func NewCapabilityCache(path string) (*CapabilityCache, error) {
+	cache := newMemoryCapabilityCache()
+	if path == "" {
+		return cache, nil
+	}
+	cache.path = path
+
+	data, err := ioutil.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var devices map[string]*capabilities
+	err = json.Unmarshal(data, &devices)
+	if err != nil {
+		return nil, fmt.Errorf("invalid capability cache %s: %w", path, err)
+	}
+	for key, entry := range devices {
+		serial, err := strconv.ParseUint(key, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid serial %s in capability cache: %w", key, err)
+		}
+		cache.devices[uint32(serial)] = entry
+	}
+	return cache, nil
+}
+
+// newMemoryCapabilityCache creates a cache that is not persisted
+func newMemoryCapabilityCache() *CapabilityCache {
+	return &CapabilityCache{
+		devices: make(map[uint32]*capabilities),
+		strikes: make(map[uint32]map[requestGroup]int),
+	}
+}
+
+// Forget capabilities of device with serial
+func (c *CapabilityCache) Forget(serial uint32) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.strikes, serial)
+	if _, ok := c.devices[serial]; ok {
+		delete(c.devices, serial)
+		c.dirty = true
+	}
+	return c.flush()
+}
+
+// supported returns the state of a request of device (known=false if not probed)
+func (c *CapabilityCache) supported(serial uint32, group requestGroup) (supported, known bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entry, ok := c.devices[serial]
+	if !ok {
+		return false, false
+	}
+	if containsGroup(entry.Supported, group) {
+		return true, true
+	}
+	if containsGroup(entry.Unsupported, group) {
+		return false, true
+	}
+	return false, false
+}
+
+// values returns the values provided by device with serial
+func (c *CapabilityCache) values(serial uint32) []ValueID {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entry, ok := c.devices[serial]
+	if !ok {
+		return nil
+	}
+	ids := append([]ValueID{}, entry.Values...)
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// markSupported stores that the request of a device succeeded and
+// returned the given values
+func (c *CapabilityCache) markSupported(serial uint32, group requestGroup, ids []ValueID) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.strikes[serial], group)
+
+	entry := c.entry(serial)
+	if !containsGroup(entry.Supported, group) {
+		entry.Unsupported = removeGroup(entry.Unsupported, group)
+		entry.Supported = append(entry.Supported, group)
+		c.dirty = true
+	}
+	for _, id := range ids {
+		if !containsValue(entry.Values, id) {
+			entry.Values = append(entry.Values, id)
+			c.dirty = true
+		}
+	}
+	c.logFlush()
+}
+
+// markUnsupported stores that the request of a device is not supported
+// after it failed unsupportedStrikes times in a row
+func (c *CapabilityCache) markUnsupported(serial uint32, group requestGroup) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.strikes[serial] == nil {
+		c.strikes[serial] = make(map[requestGroup]int)
+	}
+	c.strikes[serial][group]++
+	if c.strikes[serial][group] < unsupportedStrikes {
+		return
+	}
+	delete(c.strikes[serial], group)
+
+	entry := c.entry(serial)
+	if !containsGroup(entry.Unsupported, group) {
+		entry.Supported = removeGroup(entry.Supported, group)
+		entry.Unsupported = append(entry.Unsupported, group)
+		c.dirty = true
+	}
+	c.logFlush()
+}
+
+// entry of device with serial (mutex must be locked)
+func (c *CapabilityCache) entry(serial uint32) *capabilities {
+	entry, ok := c.devices[serial]
+	if !ok {
+		entry = new(capabilities)
+		c.devices[serial] = entry
+	}
+	return entry
+}
+
+// logFlush writes changes to file and logs errors (mutex must be locked)
+func (c *CapabilityCache) logFlush() {
+	err := c.flush()
+	if err != nil {
+		Log.Printf("failed to store capability cache: %v", err)
+	}
+}
+
+// removeGroup from groups
+func removeGroup(groups []requestGroup, group requestGroup) []requestGroup {
+	result := groups[:0]
+	for _, g := range groups {
+		if g != group {
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+// flush cache to file if it was changed (mutex must be locked)
+func (c *CapabilityCache) flush() error {
+	if !c.dirty || c.path == "" {
+		return nil
+	}
+
+	devices := make(map[string]*capabilities, len(c.devices))
+	for serial, entry := range c.devices {
+		devices[strconv.FormatUint(uint64(serial), 10)] = entry
+	}
+
+	data, err := json.MarshalIndent(devices, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(c.path, data, 0644)
+	if err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilityCache(t *testing.T) {
+	ass := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "capabilities.json")
+	cache, err := NewCapabilityCache(path)
+	if !ass.NoError(err) {
+		return
+	}
+
+	group := requestGroup{Object: 0x5100, Start: 0x00263F00, End: 0x00263FFF}
+	_, known := cache.supported(123, group)
+	ass.False(known)
+
+	// single unsupported response is not stored
+	cache.markUnsupported(123, group)
+	_, known = cache.supported(123, group)
+	ass.False(known)
+	_, err = os.Stat(path)
+	ass.True(os.IsNotExist(err))
+
+	// successful response resets the strikes
+	cache.markSupported(123, group, []ValueID{ActivePowerPlusL1})
+	cache.markUnsupported(123, group)
+	supported, known := cache.supported(123, group)
+	ass.True(known)
+	ass.True(supported)
+
+	cache.markUnsupported(123, group)
+	supported, known = cache.supported(123, group)
+	ass.True(known)
+	ass.False(supported)
+
+	cache.markSupported(123, group, []ValueID{ActivePowerPlusL1})
+	supported, known = cache.supported(123, group)
+	ass.True(known)
+	ass.True(supported)
+
+	// file is only written if something changed
+	past := time.Now().Add(-time.Hour)
+	ass.NoError(os.Chtimes(path, past, past))
+	cache.markSupported(123, group, []ValueID{ActivePowerPlusL1})
+	info, err := os.Stat(path)
+	if ass.NoError(err) {
+		ass.True(info.ModTime().Equal(past))
+	}
+	cache.markSupported(123, group, []ValueID{ActivePowerPlusL2})
+	info, err = os.Stat(path)
+	if ass.NoError(err) {
+		ass.False(info.ModTime().Equal(past))
+	}
+	ass.Equal([]ValueID{ActivePowerPlusL1, ActivePowerPlusL2}, cache.values(123))
+
+	// load from file
+	loaded, err := NewCapabilityCache(path)
+	if !ass.NoError(err) {
+		return
+	}
+	supported, known = loaded.supported(123, group)
+	ass.True(known)
+	ass.True(supported)
+	ass.Equal([]ValueID{ActivePowerPlusL1, ActivePowerPlusL2}, loaded.values(123))
+
+	ass.NoError(loaded.Forget(123))
+	_, known = loaded.supported(123, group)
+	ass.False(known)
+
+	loaded, err = NewCapabilityCache(path)
+	ass.NoError(err)
+	_, known = loaded.supported(123, group)
+	ass.False(known)
+
+	// invalid file
+	ass.NoError(ioutil.WriteFile(path, []byte("{"), 0644))
+	_, err = NewCapabilityCache(path)
+	ass.Error(err)
+}
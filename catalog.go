@@ -0,0 +1,121 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"strings"
+)
+
+// ProductFamily of SMA devices
+type ProductFamily string
+
+// Known product families
+const (
+	FamilyUnknown         ProductFamily = ""
+	FamilySunnyBoy        ProductFamily = "Sunny Boy"
+	FamilySunnyTripower   ProductFamily = "Sunny Tripower"
+	FamilySunnyIsland     ProductFamily = "Sunny Island"
+	FamilySunnyBoyStorage ProductFamily = "Sunny Boy Storage"
+	FamilyEnergyMeter     ProductFamily = "Energy Meter"
+	FamilyHomeManager     ProductFamily = "Sunny Home Manager"
+)
+
+// Product of the catalogue with capability hints
+// Note: the hints are taken from the data sheets and are only meant as
+// defaults, the values provided by a device may differ.
+type Product struct {
+	Model  string
+	Family ProductFamily
+
+	// Phases of the grid connection
+	Phases int
+	// MPPTrackers amount of independent DC inputs (0 for none)
+	MPPTrackers int
+	// Battery is connected to the device
+	Battery bool
+}
+
+// productsByType maps device type tags to products
+var productsByType = map[uint32]Product{
+	9284: {Model: "STP 20000TL-30", Phases: 3, MPPTrackers: 2},
+	9285: {Model: "STP 25000TL-30", Phases: 3, MPPTrackers: 2},
+	9301: {Model: "SB 1.5-1VL-40", Phases: 1, MPPTrackers: 1},
+	9302: {Model: "SB 2.5-1VL-40", Phases: 1, MPPTrackers: 1},
+	9303: {Model: "SB 2.0-1VL-40", Phases: 1, MPPTrackers: 1},
+	9331: {Model: "SI 3.0M-12", Phases: 1, Battery: true},
+	9332: {Model: "SI 4.4M-12", Phases: 1, Battery: true},
+	9333: {Model: "SI 6.0H-12", Phases: 1, Battery: true},
+	9334: {Model: "SI 8.0H-12", Phases: 1, Battery: true},
+	9338: {Model: "STP 50-40", Phases: 3, MPPTrackers: 6},
+	9344: {Model: "STP 4.0-3AV-40", Phases: 3, MPPTrackers: 2},
+	9345: {Model: "STP 5.0-3AV-40", Phases: 3, MPPTrackers: 2},
+	9346: {Model: "STP 6.0-3AV-40", Phases: 3, MPPTrackers: 2},
+	9347: {Model: "STP 8.0-3AV-40", Phases: 3, MPPTrackers: 2},
+	9348: {Model: "STP 10.0-3AV-40", Phases: 3, MPPTrackers: 2},
+	9356: {Model: "SBS 3.7-10", Phases: 1, Battery: true},
+	9358: {Model: "SBS 5.0-10", Phases: 1, Battery: true},
+	9359: {Model: "SBS 6.0-10", Phases: 1, Battery: true},
+	9401: {Model: "SB 3.0-1AV-41", Phases: 1, MPPTrackers: 2},
+	9402: {Model: "SB 3.6-1AV-41", Phases: 1, MPPTrackers: 2},
+	9403: {Model: "SB 4.0-1AV-41", Phases: 1, MPPTrackers: 2},
+	9404: {Model: "SB 5.0-1AV-41", Phases: 1, MPPTrackers: 2},
+	9405: {Model: "SB 6.0-1AV-41", Phases: 1, MPPTrackers: 2},
+}
+
+// productsBySusyID maps SusyIDs of devices without a type tag to products
+var productsBySusyID = map[uint16]Product{
+	270: {Model: "Energy Meter", Family: FamilyEnergyMeter, Phases: 3},
+	349: {Model: "Energy Meter 2.0", Family: FamilyEnergyMeter, Phases: 3},
+	372: {Model: "Sunny Home Manager 2.0", Family: FamilyHomeManager, Phases: 3},
+}
+
+// familyPrefixes maps model name prefixes to product families
+// (longer prefixes first)
+var familyPrefixes = []struct {
+	prefix string
+	family ProductFamily
+}{
+	{"SBS ", FamilySunnyBoyStorage},
+	{"STP ", FamilySunnyTripower},
+	{"SB ", FamilySunnyBoy},
+	{"SI ", FamilySunnyIsland},
+}
+
+// familyOfModel returns the product family of a model name
+func familyOfModel(model string) ProductFamily {
+	for _, entry := range familyPrefixes {
+		if strings.HasPrefix(model, entry.prefix) {
+			return entry.family
+		}
+	}
+	return FamilyUnknown
+}
+
+// LookupDeviceType returns the product of a device type tag
+func LookupDeviceType(tag uint32) (Product, bool) {
+	product, ok := productsByType[tag]
+	if !ok {
+		return Product{}, false
+	}
+	product.Family = familyOfModel(product.Model)
+	return product, true
+}
+
+// LookupSusyID returns the product of a SusyID
+// Note: only devices without a type tag (e.g. energy meters) are known
+func LookupSusyID(susyID uint16) (Product, bool) {
+	product, ok := productsBySusyID[susyID]
+	return product, ok
+}
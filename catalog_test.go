@@ -0,0 +1,82 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupDeviceType(t *testing.T) {
+	ass := assert.New(t)
+
+	product, ok := LookupDeviceType(9404)
+	ass.True(ok)
+	ass.Equal("SB 5.0-1AV-41", product.Model)
+	ass.Equal(FamilySunnyBoy, product.Family)
+	ass.Equal(1, product.Phases)
+
+	product, ok = LookupDeviceType(9345)
+	ass.True(ok)
+	ass.Equal(FamilySunnyTripower, product.Family)
+	ass.Equal(3, product.Phases)
+
+	product, ok = LookupDeviceType(9358)
+	ass.True(ok)
+	ass.Equal(FamilySunnyBoyStorage, product.Family)
+	ass.True(product.Battery)
+
+	product, ok = LookupDeviceType(9332)
+	ass.True(ok)
+	ass.Equal("SI 4.4M-12", product.Model)
+	ass.Equal(FamilySunnyIsland, product.Family)
+	ass.Equal(1, product.Phases)
+	ass.Equal(0, product.MPPTrackers)
+	ass.True(product.Battery)
+
+	_, ok = LookupDeviceType(1234)
+	ass.False(ok)
+
+	// all known types have a family
+	for tag := range productsByType {
+		product, _ := LookupDeviceType(tag)
+		ass.NotEqual(FamilyUnknown, product.Family, product.Model)
+	}
+}
+
+func TestLookupSusyID(t *testing.T) {
+	ass := assert.New(t)
+
+	product, ok := LookupSusyID(349)
+	ass.True(ok)
+	ass.Equal("Energy Meter 2.0", product.Model)
+	ass.Equal(FamilyEnergyMeter, product.Family)
+
+	product, ok = LookupSusyID(372)
+	ass.True(ok)
+	ass.Equal(FamilyHomeManager, product.Family)
+
+	_, ok = LookupSusyID(1)
+	ass.False(ok)
+}
+
+func TestFamilyOfModel(t *testing.T) {
+	ass := assert.New(t)
+
+	ass.Equal(FamilySunnyIsland, familyOfModel("SI 4.4M-12"))
+	ass.Equal(FamilySunnyBoyStorage, familyOfModel("SBS 2.5-1VL-10"))
+	ass.Equal(FamilyUnknown, familyOfModel("Unknown (1)"))
+}
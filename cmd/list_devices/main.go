@@ -18,63 +18,196 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"gitlab.com/bboehmke/sunny"
+	"gitlab.com/bboehmke/sunny/export"
 )
 
 var inf = flag.String("inf", "", "Interface devices are connected to")
+var format = flag.String("format", "text", "Output format (text, influx or csv)")
+var influxURL = flag.String("influx-url", "", "InfluxDB write endpoint values are pushed to (e.g. http://localhost:8086/write?db=sunny)")
+var sweep = flag.String("sweep", "", "Comma separated list of CIDR ranges or hosts to search with unicast requests")
+var sweepRate = flag.Int("sweep-rate", 50, "Maximum requests per second of the sweep")
+var influxToken = flag.String("influx-token", "", "Token for the InfluxDB write endpoint")
 
 func main() {
 	flag.Parse()
 	//sunny.Log = log.Default()
 
+	var printDevice func(device *sunny.Device)
+	switch *format {
+	case "text":
+		printDevice = printText
+	case "influx":
+		printDevice = printInflux
+	case "csv":
+		writer := export.NewCSVWriter(os.Stdout)
+		printDevice = func(device *sunny.Device) {
+			printCSV(writer, device)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "invalid format %s\n", *format)
+		os.Exit(1)
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(1)
 	devices := make(chan *sunny.Device, 10)
 
 	go func() {
 		for device := range devices {
-			fmt.Printf("==================================================\n")
-			fmt.Printf("IP:             %s\n", device.Address())
-			fmt.Printf("Serial:         %d\n", device.SerialNumber())
-			fmt.Printf("Is EnergyMeter: %v\n", device.IsEnergyMeter())
-			fmt.Printf("--------------------------------------------------\n")
-			name, err := device.GetValue(sunny.DeviceName)
-			if err != nil {
-				fmt.Printf("ERROR: %v\n", err)
-			} else {
-				fmt.Printf("Name: %s\n", name)
-			}
-			fmt.Printf("--------------------------------------------------\n")
-			values, err := device.GetValues()
-			if err != nil {
-				fmt.Printf("ERROR: %v\n", err)
-			} else {
-				for key, value := range values {
-					switch value.(type) {
-					case float64:
-						fmt.Printf("%s: %f %s\n", key, value, sunny.GetValueInfo(key).Unit)
-					default:
-						fmt.Printf("%s: %v %s\n", key, value, sunny.GetValueInfo(key).Unit)
-					}
-				}
-			}
-			fmt.Printf("==================================================\n")
-			fmt.Println()
+			printDevice(device)
 		}
 		wg.Done()
 	}()
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
-	connection, err := sunny.NewConnection(*inf)
-	if err != nil {
-		panic(err)
+	if *sweep != "" {
+		targets := strings.Split(*sweep, ",")
+		ctx, cancel := context.WithTimeout(context.Background(), sweepDuration(targets))
+		err := sunny.SweepDiscoverDevices(ctx, targets, *sweepRate, devices, "0000")
+		cancel()
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		connection, err := sunny.NewConnection(*inf)
+		if err != nil {
+			panic(err)
+		}
+		connection.DiscoverDevices(ctx, devices, "0000")
+		cancel()
 	}
-	connection.DiscoverDevices(ctx, devices, "0000")
-	cancel()
 
 	close(devices)
 	wg.Wait()
 }
+
+// sweepDuration estimates the duration to request all hosts of the targets
+func sweepDuration(targets []string) time.Duration {
+	hosts := 0
+	for _, target := range targets {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(target))
+		if err != nil {
+			hosts++
+			continue
+		}
+		ones, bits := network.Mask.Size()
+		hosts += 1 << uint(bits-ones)
+	}
+	rate := *sweepRate
+	if rate <= 0 {
+		rate = 50
+	}
+	// time for requests + time for responses
+	return time.Second*time.Duration(hosts)/time.Duration(rate) + time.Second*3
+}
+
+// printText prints device information and values human readable
+func printText(device *sunny.Device) {
+	fmt.Printf("==================================================\n")
+	fmt.Printf("IP:             %s\n", device.Address())
+	fmt.Printf("Serial:         %d\n", device.SerialNumber())
+	fmt.Printf("Is EnergyMeter: %v\n", device.IsEnergyMeter())
+	fmt.Printf("--------------------------------------------------\n")
+	info, err := device.Info(context.Background())
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+	} else {
+		fmt.Printf("Name:           %s\n", info.Name)
+		fmt.Printf("Model:          %s\n", info.Model)
+		fmt.Printf("SusyID:         %d\n", info.SusyID)
+		if info.Product.Family != sunny.FamilyUnknown {
+			fmt.Printf("Family:         %s\n", info.Product.Family)
+			fmt.Printf("Phases:         %d\n", info.Product.Phases)
+			fmt.Printf("MPP trackers:   %d\n", info.Product.MPPTrackers)
+			fmt.Printf("Battery:        %v\n", info.Product.Battery)
+		}
+		fmt.Printf("Firmware:       %s\n", info.Firmware)
+		if info.NominalPower > 0 {
+			fmt.Printf("Nominal power:  %.0f W\n", info.NominalPower)
+		}
+	}
+	fmt.Printf("--------------------------------------------------\n")
+	values, err := device.GetTimedValues()
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+	} else {
+		for key, value := range values {
+			switch value.Value.(type) {
+			case float64:
+				fmt.Printf("%s: %f %s\n", key, value.Value, sunny.GetValueInfo(key).Unit)
+			default:
+				fmt.Printf("%s: %v %s\n", key, value.Value, sunny.GetValueInfo(key).Unit)
+			}
+		}
+		pushInflux(device, values)
+	}
+	fmt.Printf("==================================================\n")
+	fmt.Println()
+}
+
+// printInflux prints device values in InfluxDB line protocol
+func printInflux(device *sunny.Device) {
+	values, err := device.GetTimedValues()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %d: %v\n", device.SerialNumber(), err)
+		return
+	}
+
+	err = export.WriteInflux(os.Stdout, export.Measurement{
+		Serial: device.SerialNumber(),
+		Values: values,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+	}
+	pushInflux(device, values)
+}
+
+// printCSV prints device values as CSV row
+func printCSV(writer *export.CSVWriter, device *sunny.Device) {
+	values, err := device.GetTimedValues()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %d: %v\n", device.SerialNumber(), err)
+		return
+	}
+
+	err = writer.Write(export.Measurement{
+		Serial: device.SerialNumber(),
+		Values: values,
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+	}
+	pushInflux(device, values)
+}
+
+// pushInflux sends values to InfluxDB write endpoint if configured
+func pushInflux(device *sunny.Device, values map[sunny.ValueID]sunny.TimedValue) {
+	if *influxURL == "" || values == nil {
+		return
+	}
+
+	client := export.InfluxClient{
+		URL:   *influxURL,
+		Token: *influxToken,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	err := client.Write(ctx, export.Measurement{
+		Serial: device.SerialNumber(),
+		Values: values,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// deviceState with the last polled values of a device
+type deviceState struct {
+	serial string
+	class  string
+
+	up       bool
+	duration time.Duration
+	values   map[sunny.ValueID]interface{}
+}
+
+// collector polls devices on a schedule and serves the values as metrics
+type collector struct {
+	interval time.Duration
+	timeout  time.Duration
+
+	mutex  sync.RWMutex
+	states map[string]*deviceState
+}
+
+// newCollector creates a new collector
+func newCollector(interval, timeout time.Duration) *collector {
+	return &collector{
+		interval: interval,
+		timeout:  timeout,
+		states:   make(map[string]*deviceState),
+	}
+}
+
+// addDevice and start polling it
+func (c *collector) addDevice(device sunny.ValueReader) {
+	state := &deviceState{
+		serial: strconv.FormatUint(uint64(device.SerialNumber()), 10),
+		class:  "inverter",
+	}
+	if device.IsEnergyMeter() {
+		state.class = "energy_meter"
+	}
+
+	c.mutex.Lock()
+	c.states[state.serial] = state
+	c.mutex.Unlock()
+
+	go c.pollLoop(device, state)
+}
+
+// pollLoop requests values of device until the process exits
+func (c *collector) pollLoop(device sunny.ValueReader, state *deviceState) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+		values, err := device.GetValuesCtx(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("failed to poll device %s: %v", state.serial, err)
+		}
+
+		c.mutex.Lock()
+		state.up = err == nil
+		state.duration = time.Since(start)
+		if err == nil {
+			state.values = values
+		}
+		c.mutex.Unlock()
+
+		<-ticker.C
+	}
+}
+
+// ServeHTTP writes the metrics of all devices
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	metrics := newMetricSet()
+
+	c.mutex.RLock()
+	for _, state := range c.states {
+		up := 0.
+		if state.up {
+			up = 1
+		}
+		metrics.add(namespace+"_up", "Last poll of device was successful", "gauge",
+			up, "serial", state.serial, "class", state.class)
+		metrics.add(namespace+"_poll_duration_seconds", "Duration of last device poll", "gauge",
+			state.duration.Seconds(), "serial", state.serial, "class", state.class)
+
+		if name, ok := state.values[sunny.DeviceName].(string); ok {
+			metrics.add(namespace+"_device_info", "Information about the device", "gauge",
+				1, "serial", state.serial, "class", state.class, "name", name)
+		}
+		for id, value := range state.values {
+			metrics.addValue(state.serial, state.class, id, value)
+		}
+	}
+	c.mutex.RUnlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, err := metrics.WriteTo(w)
+	if err != nil {
+		log.Printf("failed to write metrics: %v", err)
+	}
+}
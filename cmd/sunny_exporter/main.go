@@ -0,0 +1,94 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+	"gitlab.com/bboehmke/sunny/modbus"
+)
+
+var inf = flag.String("inf", "", "Interface devices are connected to")
+var devices = flag.String("devices", "", "Comma separated list of device addresses (disables discovery)")
+var password = flag.String("password", "0000", "User password of the inverters")
+var listen = flag.String("listen", ":9547", "Address of the metrics HTTP server")
+var interval = flag.Duration("interval", time.Second*15, "Interval between two device polls")
+var unicast = flag.Bool("unicast", false, "Access devices of -devices via unicast (routed networks without multicast)")
+var modbusDevices = flag.String("modbus", "", "Comma separated list of inverter Modbus TCP addresses (host[:port])")
+var modbusUnit = flag.Uint("modbus-unit", uint(modbus.DefaultUnit), "Modbus unit ID of the inverters")
+var timeout = flag.Duration("timeout", time.Second*5, "Timeout of a single device poll")
+
+func main() {
+	flag.Parse()
+
+	var deviceList []sunny.ValueReader
+	if *modbusDevices != "" {
+		for _, address := range strings.Split(*modbusDevices, ",") {
+			device, err := modbus.NewDevice(strings.TrimSpace(address), uint8(*modbusUnit))
+			if err != nil {
+				log.Printf("skip modbus device %s: %v", address, err)
+				continue
+			}
+			deviceList = append(deviceList, device)
+		}
+	} else if *unicast {
+		for _, address := range strings.Split(*devices, ",") {
+			device, err := sunny.NewUnicastDevice(strings.TrimSpace(address), *password)
+			if err != nil {
+				log.Printf("skip device %s: %v", address, err)
+				continue
+			}
+			deviceList = append(deviceList, device)
+		}
+	} else {
+		connection, err := sunny.NewConnection(*inf)
+		if err != nil {
+			log.Fatalf("failed to open connection: %v", err)
+		}
+
+		if *devices != "" {
+			for _, address := range strings.Split(*devices, ",") {
+				device, err := connection.NewDevice(strings.TrimSpace(address), *password)
+				if err != nil {
+					log.Printf("skip device %s: %v", address, err)
+					continue
+				}
+				deviceList = append(deviceList, device)
+			}
+		} else {
+			for _, device := range connection.SimpleDiscoverDevices(*password) {
+				deviceList = append(deviceList, device)
+			}
+		}
+	}
+	if len(deviceList) == 0 {
+		log.Fatalf("no devices found")
+	}
+
+	collector := newCollector(*interval, *timeout)
+	for _, device := range deviceList {
+		log.Printf("poll device %d", device.SerialNumber())
+		collector.addDevice(device)
+	}
+
+	http.Handle("/metrics", collector)
+	log.Printf("listen on %s", *listen)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
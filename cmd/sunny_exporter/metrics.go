@@ -0,0 +1,228 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gitlab.com/bboehmke/sunny"
+	"gitlab.com/bboehmke/sunny/export"
+)
+
+// metric prefix for all exported values
+const namespace = "sunny"
+
+// unitSuffix maps value units to prometheus base unit suffixes
+var unitSuffix = map[string]string{
+	"W":    "watts",
+	"VA":   "voltamperes",
+	"var":  "vars",
+	"Ws":   "joules",
+	"VAs":  "voltampere_seconds",
+	"vars": "var_seconds",
+	"A":    "amperes",
+	"V":    "volts",
+	"s":    "seconds",
+	"Hz":   "hertz",
+	"%":    "percent",
+	"°C":   "celsius",
+}
+
+// metricDesc describes a metric created from a ValueID
+type metricDesc struct {
+	Name    string
+	Help    string
+	Type    string
+	Phase   string
+	String  string
+	Counter bool
+}
+
+// describeValue creates metric description for the given value
+func describeValue(id sunny.ValueID) metricDesc {
+	info := sunny.GetValueInfo(id)
+	name, phase, str := export.SplitChannel(id)
+
+	desc := metricDesc{
+		Help:    info.Description,
+		Type:    "gauge",
+		Phase:   phase,
+		String:  str,
+		Counter: info.Type == "energy",
+	}
+	if desc.Counter {
+		desc.Type = "counter"
+	}
+
+	// samples of all phases and strings share the help text of the metric
+	if phase != "" {
+		desc.Help = strings.Replace(desc.Help, " "+phase, "", 1)
+	}
+	if str != "" {
+		desc.Help = strings.Replace(desc.Help, " String "+str, "", 1)
+	}
+
+	parts := []string{namespace, snakeCase(name)}
+	if suffix, ok := unitSuffix[info.Unit]; ok {
+		parts = append(parts, suffix)
+	}
+	if desc.Counter {
+		parts = append(parts, "total")
+	}
+	desc.Name = strings.Join(parts, "_")
+	return desc
+}
+
+// snakeCase converts a camel case name to snake case
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// sample of a metric
+type sample struct {
+	labels []string // pairs of name and value
+	value  float64
+}
+
+// labelString returns the formatted label set
+func (s sample) labelString() string {
+	if len(s.labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(s.labels)/2)
+	for i := 0; i+1 < len(s.labels); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s.labels[i+1])
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, s.labels[i], value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// metricFamily with all samples of one metric name
+type metricFamily struct {
+	help    string
+	typ     string
+	samples []sample
+}
+
+// metricSet collects samples and writes them in text exposition format
+type metricSet struct {
+	families map[string]*metricFamily
+}
+
+// newMetricSet creates an empty metric set
+func newMetricSet() *metricSet {
+	return &metricSet{
+		families: make(map[string]*metricFamily),
+	}
+}
+
+// add sample to the metric with the given name
+func (m *metricSet) add(name, help, typ string, value float64, labels ...string) {
+	family, ok := m.families[name]
+	if !ok {
+		family = &metricFamily{
+			help: help,
+			typ:  typ,
+		}
+		m.families[name] = family
+	}
+	family.samples = append(family.samples, sample{
+		labels: labels,
+		value:  value,
+	})
+}
+
+// addValue of a device to the metric set
+func (m *metricSet) addValue(serial, class string, id sunny.ValueID, value interface{}) {
+	v, ok := toFloat(value)
+	if !ok {
+		return // no numeric value
+	}
+
+	desc := describeValue(id)
+	labels := []string{"serial", serial, "class", class}
+	if desc.Phase != "" {
+		labels = append(labels, "phase", desc.Phase)
+	}
+	if desc.String != "" {
+		labels = append(labels, "string", desc.String)
+	}
+	m.add(desc.Name, desc.Help, desc.Type, v, labels...)
+}
+
+// WriteTo writes all metrics in text exposition format
+func (m *metricSet) WriteTo(w io.Writer) (int64, error) {
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var written int64
+	for _, name := range names {
+		family := m.families[name]
+		sort.Slice(family.samples, func(i, j int) bool {
+			return family.samples[i].labelString() < family.samples[j].labelString()
+		})
+
+		n, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, family.help, name, family.typ)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+		for _, s := range family.samples {
+			n, err = fmt.Fprintf(w, "%s%s %g\n", name, s.labelString(), s.value)
+			written += int64(n)
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// toFloat converts numeric values to float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case uint64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+func TestSnakeCase(t *testing.T) {
+	ass := assert.New(t)
+
+	tests := map[string]string{
+		"":                "",
+		"Power":           "power",
+		"ActivePowerPlus": "active_power_plus",
+		"deviceName":      "device_name",
+	}
+	for input, expected := range tests {
+		ass.Equal(expected, snakeCase(input), input)
+	}
+}
+
+func TestDescribeValue(t *testing.T) {
+	ass := assert.New(t)
+
+	tests := []struct {
+		id   sunny.ValueID
+		desc metricDesc
+	}{
+		{sunny.ActivePowerPlus, metricDesc{
+			Name: "sunny_active_power_plus_watts",
+			Help: "Active power + (AC)",
+			Type: "gauge",
+		}},
+		{sunny.ActivePowerPlusL2, metricDesc{
+			Name:  "sunny_active_power_plus_watts",
+			Help:  "Active power + (AC)",
+			Type:  "gauge",
+			Phase: "L2",
+		}},
+		{sunny.PowerS1, metricDesc{
+			Name:   "sunny_power_watts",
+			Help:   "Power (DC)",
+			Type:   "gauge",
+			String: "1",
+		}},
+		{sunny.VoltageL1, metricDesc{
+			Name:  "sunny_voltage_volts",
+			Help:  "Voltage (AC)",
+			Type:  "gauge",
+			Phase: "L1",
+		}},
+		{sunny.ActiveEnergyPlus, metricDesc{
+			Name:    "sunny_active_energy_plus_joules_total",
+			Help:    "Active Energy + (AC)",
+			Type:    "counter",
+			Counter: true,
+		}},
+	}
+	for _, test := range tests {
+		ass.Equal(test.desc, describeValue(test.id), test.id.String())
+	}
+}
+
+func TestMetricSet_WriteTo(t *testing.T) {
+	ass := assert.New(t)
+
+	metrics := newMetricSet()
+	metrics.addValue("2", "Solar Inverters", sunny.ActivePowerPlusL1, 400.5)
+	metrics.addValue("1", "Energy Meter", sunny.ActivePowerPlus, uint32(1200))
+	metrics.addValue("1", "Energy Meter", sunny.ActiveEnergyPlus, uint64(3600000))
+	metrics.addValue("2", "Solar Inverters", sunny.PowerS1, int32(800))
+	metrics.addValue("2", "Solar Inverters", sunny.DeviceName, "no number")
+	metrics.add("sunny_up", `device "up"`, "gauge", 1, "serial", `a"b\c`)
+
+	var buffer bytes.Buffer
+	n, err := metrics.WriteTo(&buffer)
+	ass.NoError(err)
+	ass.Equal(int64(buffer.Len()), n)
+	ass.Equal(`# HELP sunny_active_energy_plus_joules_total Active Energy + (AC)
+# TYPE sunny_active_energy_plus_joules_total counter
+sunny_active_energy_plus_joules_total{serial="1",class="Energy Meter"} 3.6e+06
+# HELP sunny_active_power_plus_watts Active power + (AC)
+# TYPE sunny_active_power_plus_watts gauge
+sunny_active_power_plus_watts{serial="1",class="Energy Meter"} 1200
+sunny_active_power_plus_watts{serial="2",class="Solar Inverters",phase="L1"} 400.5
+# HELP sunny_power_watts Power (DC)
+# TYPE sunny_power_watts gauge
+sunny_power_watts{serial="2",class="Solar Inverters",string="1"} 800
+# HELP sunny_up device "up"
+# TYPE sunny_up gauge
+sunny_up{serial="a\"b\\c"} 1
+`, buffer.String())
+}
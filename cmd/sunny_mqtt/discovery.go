@@ -0,0 +1,122 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// haAvailability entry of discovery config
+type haAvailability struct {
+	Topic string `json:"topic"`
+}
+
+// haDevice entry of discovery config
+type haDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model,omitempty"`
+}
+
+// haSensorConfig for Home Assistant MQTT discovery
+type haSensorConfig struct {
+	Name             string           `json:"name"`
+	UniqueID         string           `json:"unique_id"`
+	StateTopic       string           `json:"state_topic"`
+	Availability     []haAvailability `json:"availability"`
+	AvailabilityMode string           `json:"availability_mode"`
+	Device           haDevice         `json:"device"`
+
+	DeviceClass       string `json:"device_class,omitempty"`
+	StateClass        string `json:"state_class,omitempty"`
+	UnitOfMeasurement string `json:"unit_of_measurement,omitempty"`
+	ValueTemplate     string `json:"value_template,omitempty"`
+}
+
+// haDeviceClasses maps value types and units to Home Assistant device classes
+var haDeviceClasses = map[string]map[string]string{
+	"power": {
+		"W":   "power",
+		"VA":  "apparent_power",
+		"var": "reactive_power",
+	},
+	"energy": {
+		"Ws": "energy",
+	},
+	"voltage": {
+		"V": "voltage",
+	},
+	"current": {
+		"A": "current",
+	},
+	"temperature": {
+		"°C": "temperature",
+	},
+}
+
+// discoveryConfig creates the discovery topic and config of a device value
+func (p *publisher) discoveryConfig(state *deviceState, id sunny.ValueID, value interface{}) (mqttMessage, error) {
+	info := sunny.GetValueInfo(id)
+
+	config := haSensorConfig{
+		Name:       info.Description,
+		UniqueID:   fmt.Sprintf("sunny_%s_%s", state.serial, id),
+		StateTopic: p.valueTopic(state, id),
+		Availability: []haAvailability{
+			{Topic: p.statusTopic()},
+			{Topic: p.availabilityTopic(state)},
+		},
+		AvailabilityMode: "all",
+		Device: haDevice{
+			Identifiers:  []string{"sunny_" + state.serial},
+			Name:         state.name,
+			Manufacturer: "SMA",
+			Model:        state.model,
+		},
+		DeviceClass:       haDeviceClasses[info.Type][info.Unit],
+		UnitOfMeasurement: info.Unit,
+	}
+
+	// only numeric values have a state class
+	if _, ok := value.(string); !ok {
+		config.StateClass = "measurement"
+	}
+
+	if info.Type == "energy" {
+		config.StateClass = "total_increasing"
+
+		// energy values are in Ws -> Home Assistant requires kWh
+		if info.Unit == "Ws" {
+			config.UnitOfMeasurement = "kWh"
+			config.ValueTemplate = "{{ (value | float / 3600000) | round(3) }}"
+		}
+	}
+
+	payload, err := json.Marshal(config)
+	if err != nil {
+		return mqttMessage{}, err
+	}
+
+	return mqttMessage{
+		Topic: fmt.Sprintf("%s/sensor/sunny_%s/%s/config",
+			p.discoveryPrefix, state.serial, id),
+		Payload: payload,
+		Retain:  true,
+	}, nil
+}
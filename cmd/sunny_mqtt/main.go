@@ -0,0 +1,100 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+var inf = flag.String("inf", "", "Interface devices are connected to")
+var devices = flag.String("devices", "", "Comma separated list of device addresses (disables discovery)")
+var password = flag.String("password", "0000", "User password of the inverters")
+var interval = flag.Duration("interval", time.Second*15, "Interval between two device polls")
+var timeout = flag.Duration("timeout", time.Second*5, "Timeout of a single device poll")
+
+var broker = flag.String("broker", "localhost:1883", "Address of the MQTT broker")
+var clientID = flag.String("client-id", "sunny", "MQTT client ID")
+var username = flag.String("username", "", "MQTT user name")
+var mqttPassword = flag.String("mqtt-password", "", "MQTT password")
+var topic = flag.String("topic", "sunny", "Prefix of all published topics")
+var retain = flag.Bool("retain", false, "Publish values as retained messages")
+var discovery = flag.Bool("discovery", true, "Publish Home Assistant discovery configs")
+var discoveryPrefix = flag.String("discovery-prefix", "homeassistant", "Prefix of Home Assistant discovery topics")
+
+func main() {
+	flag.Parse()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	connection, err := sunny.NewConnection(*inf)
+	if err != nil {
+		log.Fatalf("failed to open connection: %v", err)
+	}
+
+	var deviceList []*sunny.Device
+	if *devices != "" {
+		for _, address := range strings.Split(*devices, ",") {
+			device, err := connection.NewDevice(strings.TrimSpace(address), *password)
+			if err != nil {
+				log.Printf("skip device %s: %v", address, err)
+				continue
+			}
+			deviceList = append(deviceList, device)
+		}
+	} else {
+		deviceList = connection.SimpleDiscoverDevices(*password)
+	}
+	if len(deviceList) == 0 {
+		log.Fatalf("no devices found")
+	}
+
+	pub := &publisher{
+		topicPrefix:     *topic,
+		discoveryPrefix: *discoveryPrefix,
+		discovery:       *discovery,
+		retain:          *retain,
+		interval:        *interval,
+		timeout:         *timeout,
+	}
+	pub.client = &mqttClient{
+		address:   *broker,
+		clientID:  *clientID,
+		username:  *username,
+		password:  *mqttPassword,
+		keepAlive: time.Second * 30,
+		will: &mqttMessage{
+			Topic:   pub.statusTopic(),
+			Payload: []byte(payloadOffline),
+			Retain:  true,
+		},
+		onConnect: pub.onConnect,
+	}
+
+	for _, device := range deviceList {
+		log.Printf("publish device %d at %s", device.SerialNumber(), device.Address().IP)
+		pub.addDevice(ctx, device)
+	}
+
+	pub.client.Run(ctx)
+}
@@ -0,0 +1,326 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// MQTT 3.1.1 packet types
+const (
+	mqttConnect    byte = 0x10
+	mqttConnAck    byte = 0x20
+	mqttPublish    byte = 0x30
+	mqttPingReq    byte = 0xC0
+	mqttPingResp   byte = 0xD0
+	mqttDisconnect byte = 0xE0
+)
+
+// errNotConnected is returned if a message is published without connection
+var errNotConnected = errors.New("not connected to broker")
+
+// mqttMessage to publish
+type mqttMessage struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// mqttClient is a minimal MQTT 3.1.1 client with QoS 0 publish support
+type mqttClient struct {
+	address   string
+	clientID  string
+	username  string
+	password  string
+	keepAlive time.Duration
+
+	// last will message (e.g. for availability)
+	will *mqttMessage
+
+	// called after every successful connect
+	onConnect func()
+
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+// Publish message to broker
+func (c *mqttClient) Publish(msg mqttMessage) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.conn == nil {
+		return errNotConnected
+	}
+
+	err := c.writePacket(publishPacket(msg))
+	if err != nil {
+		// connection broken -> force reconnect
+		_ = c.conn.Close()
+	}
+	return err
+}
+
+// Run connects to the broker and reconnects until the context is canceled
+func (c *mqttClient) Run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := c.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		// connection was stable for a while -> reset backoff
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("mqtt connection to %s lost: %v (reconnect in %s)", c.address, err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
+
+// session handles a single connection to the broker
+func (c *mqttClient) session(ctx context.Context) error {
+	conn, err := net.DialTimeout("tcp", c.address, time.Second*10)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	err = c.handshake(conn, reader)
+	if err != nil {
+		return err
+	}
+	log.Printf("connected to mqtt broker %s", c.address)
+
+	c.mutex.Lock()
+	c.conn = conn
+	c.mutex.Unlock()
+	defer func() {
+		c.mutex.Lock()
+		c.conn = nil
+		c.mutex.Unlock()
+	}()
+
+	if c.onConnect != nil {
+		go c.onConnect()
+	}
+
+	// read incoming packets
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			header, _, err := readPacket(reader)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if header&0xF0 != mqttPingResp {
+				log.Printf("mqtt ignore packet 0x%X", header)
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(c.keepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			c.mutex.Lock()
+			// graceful disconnect suppresses the last will -> send it manually
+			if c.will != nil {
+				_ = c.writePacket(publishPacket(*c.will))
+			}
+			_ = c.writePacket(mqttDisconnect, nil)
+			c.mutex.Unlock()
+			return nil
+
+		case err := <-readErr:
+			return err
+
+		case <-ticker.C:
+			c.mutex.Lock()
+			err := c.writePacket(mqttPingReq, nil)
+			c.mutex.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handshake sends the connect packet and waits for the acknowledge
+func (c *mqttClient) handshake(conn net.Conn, reader *bufio.Reader) error {
+	var body bytes.Buffer
+	writeString(&body, "MQTT")
+	body.WriteByte(4) // protocol level 3.1.1
+
+	flags := byte(0x02) // clean session
+	if c.will != nil {
+		flags |= 0x04
+		if c.will.Retain {
+			flags |= 0x20
+		}
+	}
+	if c.username != "" {
+		flags |= 0x80
+		if c.password != "" {
+			flags |= 0x40
+		}
+	}
+	body.WriteByte(flags)
+
+	keepAlive := make([]byte, 2)
+	binary.BigEndian.PutUint16(keepAlive, uint16(c.keepAlive.Seconds()))
+	body.Write(keepAlive)
+
+	writeString(&body, c.clientID)
+	if c.will != nil {
+		writeString(&body, c.will.Topic)
+		writeBytes(&body, c.will.Payload)
+	}
+	if c.username != "" {
+		writeString(&body, c.username)
+		if c.password != "" {
+			writeString(&body, c.password)
+		}
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(time.Second * 10))
+	defer conn.SetDeadline(time.Time{})
+
+	_, err := conn.Write(encodePacket(mqttConnect, body.Bytes()))
+	if err != nil {
+		return err
+	}
+
+	header, data, err := readPacket(reader)
+	if err != nil {
+		return err
+	}
+	if header != mqttConnAck || len(data) != 2 {
+		return fmt.Errorf("invalid connect response 0x%X", header)
+	}
+	if data[1] != 0 {
+		return fmt.Errorf("connection refused by broker (code %d)", data[1])
+	}
+	return nil
+}
+
+// writePacket to connection (mutex must be locked)
+func (c *mqttClient) writePacket(header byte, body []byte) error {
+	if c.conn == nil {
+		return errNotConnected
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(time.Second * 10))
+	_, err := c.conn.Write(encodePacket(header, body))
+	return err
+}
+
+// publishPacket returns header and body of a QoS 0 publish packet
+func publishPacket(msg mqttMessage) (byte, []byte) {
+	header := mqttPublish
+	if msg.Retain {
+		header |= 0x01
+	}
+
+	var body bytes.Buffer
+	writeString(&body, msg.Topic)
+	body.Write(msg.Payload)
+	return header, body.Bytes()
+}
+
+// encodePacket with fixed header and remaining length
+func encodePacket(header byte, body []byte) []byte {
+	data := []byte{header}
+
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			b |= 0x80
+		}
+		data = append(data, b)
+		if length == 0 {
+			break
+		}
+	}
+	return append(data, body...)
+}
+
+// readPacket from reader and returns header and body
+func readPacket(reader *bufio.Reader) (byte, []byte, error) {
+	header, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length := 0
+	multiplier := 1
+	for i := 0; ; i++ {
+		if i >= 4 {
+			return 0, nil, fmt.Errorf("invalid remaining length")
+		}
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(b&0x7F) * multiplier
+		multiplier *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(reader, body)
+	return header, body, err
+}
+
+// writeString with length prefix
+func writeString(buffer *bytes.Buffer, s string) {
+	writeBytes(buffer, []byte(s))
+}
+
+// writeBytes with length prefix
+func writeBytes(buffer *bytes.Buffer, data []byte) {
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(data)))
+	buffer.Write(length)
+	buffer.Write(data)
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodePacket(t *testing.T) {
+	ass := assert.New(t)
+
+	tests := []struct {
+		length int
+		header []byte
+	}{
+		{0, []byte{0x30, 0x00}},
+		{127, []byte{0x30, 0x7F}},
+		{128, []byte{0x30, 0x80, 0x01}},
+		{16383, []byte{0x30, 0xFF, 0x7F}},
+		{16384, []byte{0x30, 0x80, 0x80, 0x01}},
+		{2097151, []byte{0x30, 0xFF, 0xFF, 0x7F}},
+		{2097152, []byte{0x30, 0x80, 0x80, 0x80, 0x01}},
+	}
+	for _, test := range tests {
+		body := bytes.Repeat([]byte{0xAA}, test.length)
+		data := encodePacket(mqttPublish, body)
+		ass.Equal(test.header, data[:len(test.header)], "length %d", test.length)
+		ass.Len(data, len(test.header)+test.length)
+
+		header, read, err := readPacket(bufio.NewReader(bytes.NewReader(data)))
+		ass.NoError(err)
+		ass.Equal(mqttPublish, header)
+		ass.Equal(body, read)
+	}
+}
+
+func TestReadPacket(t *testing.T) {
+	ass := assert.New(t)
+
+	header, body, err := readPacket(bufio.NewReader(bytes.NewReader([]byte{0x20, 0x02, 0x00, 0x00})))
+	ass.NoError(err)
+	ass.Equal(mqttConnAck, header)
+	ass.Equal([]byte{0x00, 0x00}, body)
+
+	// remaining length with more than 4 bytes
+	_, _, err = readPacket(bufio.NewReader(bytes.NewReader([]byte{0x30, 0xFF, 0xFF, 0xFF, 0xFF, 0x01})))
+	ass.Error(err)
+
+	// truncated body
+	_, _, err = readPacket(bufio.NewReader(bytes.NewReader([]byte{0x30, 0x03, 0x00})))
+	ass.Equal(io.ErrUnexpectedEOF, err)
+
+	_, _, err = readPacket(bufio.NewReader(bytes.NewReader(nil)))
+	ass.Equal(io.EOF, err)
+}
+
+func TestPublishPacket(t *testing.T) {
+	ass := assert.New(t)
+
+	header, body := publishPacket(mqttMessage{Topic: "a/b", Payload: []byte("x")})
+	ass.Equal([]byte{0x30, 0x06, 0x00, 0x03, 'a', '/', 'b', 'x'}, encodePacket(header, body))
+
+	header, body = publishPacket(mqttMessage{Topic: "a/b", Payload: []byte("x"), Retain: true})
+	ass.Equal([]byte{0x31, 0x06, 0x00, 0x03, 'a', '/', 'b', 'x'}, encodePacket(header, body))
+}
+
+// handshake runs the connect handshake against a fake broker
+func handshake(client *mqttClient, connAck []byte) ([]byte, error) {
+	conn, broker := net.Pipe()
+	defer conn.Close()
+	defer broker.Close()
+
+	connect := make(chan []byte, 1)
+	go func() {
+		_ = broker.SetDeadline(time.Now().Add(time.Second))
+		header, body, err := readPacket(bufio.NewReader(broker))
+		if err != nil {
+			connect <- nil
+			return
+		}
+		connect <- encodePacket(header, body)
+		_, _ = broker.Write(connAck)
+	}()
+
+	err := client.handshake(conn, bufio.NewReader(conn))
+	return <-connect, err
+}
+
+func TestMqttClient_Handshake(t *testing.T) {
+	ass := assert.New(t)
+
+	client := &mqttClient{
+		clientID:  "sunny",
+		keepAlive: time.Minute,
+	}
+	connect, err := handshake(client, []byte{0x20, 0x02, 0x00, 0x00})
+	ass.NoError(err)
+	ass.Equal([]byte{
+		0x10, 0x11,
+		0x00, 0x04, 'M', 'Q', 'T', 'T', // protocol name
+		0x04,       // protocol level
+		0x02,       // clean session
+		0x00, 0x3C, // keep alive
+		0x00, 0x05, 's', 'u', 'n', 'n', 'y', // client ID
+	}, connect)
+
+	client = &mqttClient{
+		clientID:  "s",
+		username:  "u",
+		password:  "p",
+		keepAlive: time.Second * 10,
+		will:      &mqttMessage{Topic: "t", Payload: []byte("off"), Retain: true},
+	}
+	connect, err = handshake(client, []byte{0x20, 0x02, 0x00, 0x00})
+	ass.NoError(err)
+	ass.Equal([]byte{
+		0x10, 0x1B,
+		0x00, 0x04, 'M', 'Q', 'T', 'T',
+		0x04,
+		0xE6, // user, password, will retain, will, clean session
+		0x00, 0x0A,
+		0x00, 0x01, 's',
+		0x00, 0x01, 't', // will topic
+		0x00, 0x03, 'o', 'f', 'f', // will payload
+		0x00, 0x01, 'u',
+		0x00, 0x01, 'p',
+	}, connect)
+
+	// refused by broker
+	_, err = handshake(client, []byte{0x20, 0x02, 0x00, 0x05})
+	ass.Error(err)
+
+	// invalid response
+	_, err = handshake(client, []byte{0x30, 0x00})
+	ass.Error(err)
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// availability payloads
+const (
+	payloadOnline  = "online"
+	payloadOffline = "offline"
+)
+
+// deviceState of a published device
+type deviceState struct {
+	device *sunny.Device
+	serial string
+	name   string
+	model  string
+
+	// true if device responded on last poll
+	online bool
+	// values with published discovery config
+	announced map[sunny.ValueID]bool
+}
+
+// publisher polls devices and publishes the values via MQTT
+type publisher struct {
+	client *mqttClient
+
+	topicPrefix     string
+	discoveryPrefix string
+	discovery       bool
+	retain          bool
+
+	interval time.Duration
+	timeout  time.Duration
+
+	mutex   sync.Mutex
+	devices []*deviceState
+}
+
+// statusTopic for availability of the publisher itself
+func (p *publisher) statusTopic() string {
+	return p.topicPrefix + "/status"
+}
+
+// availabilityTopic of a device
+func (p *publisher) availabilityTopic(state *deviceState) string {
+	return fmt.Sprintf("%s/%s/availability", p.topicPrefix, state.serial)
+}
+
+// valueTopic of a device value
+func (p *publisher) valueTopic(state *deviceState, id sunny.ValueID) string {
+	return fmt.Sprintf("%s/%s/%s", p.topicPrefix, state.serial, id)
+}
+
+// addDevice and start polling it
+func (p *publisher) addDevice(ctx context.Context, device *sunny.Device) {
+	state := &deviceState{
+		device:    device,
+		serial:    strconv.FormatUint(uint64(device.SerialNumber()), 10),
+		model:     "Inverter",
+		announced: make(map[sunny.ValueID]bool),
+	}
+	state.name = "SMA " + state.serial
+	if device.IsEnergyMeter() {
+		state.model = "Energy Meter"
+	}
+
+	p.mutex.Lock()
+	p.devices = append(p.devices, state)
+	p.mutex.Unlock()
+
+	go p.pollLoop(ctx, state)
+}
+
+// onConnect publishes availability and forces new discovery configs
+func (p *publisher) onConnect() {
+	p.publish(p.statusTopic(), payloadOnline, true)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, state := range p.devices {
+		state.announced = make(map[sunny.ValueID]bool)
+		p.publishAvailability(state)
+	}
+}
+
+// pollLoop requests values of device until the context is canceled
+func (p *publisher) pollLoop(ctx context.Context, state *deviceState) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		pollCtx, cancel := context.WithTimeout(ctx, p.timeout)
+		values, err := state.device.GetValuesCtx(pollCtx)
+		cancel()
+
+		p.mutex.Lock()
+		if err != nil {
+			log.Printf("failed to poll device %s: %v", state.serial, err)
+		}
+		if state.online != (err == nil) {
+			state.online = err == nil
+			p.publishAvailability(state)
+		}
+		if err == nil {
+			p.publishValues(state, values)
+		}
+		p.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishAvailability of device (mutex must be locked)
+func (p *publisher) publishAvailability(state *deviceState) {
+	payload := payloadOffline
+	if state.online {
+		payload = payloadOnline
+	}
+	p.publish(p.availabilityTopic(state), payload, true)
+}
+
+// publishValues of device with discovery config (mutex must be locked)
+func (p *publisher) publishValues(state *deviceState, values map[sunny.ValueID]interface{}) {
+	if name, ok := values[sunny.DeviceName].(string); ok && name != "" {
+		state.name = name
+	}
+
+	for id, value := range values {
+		if p.discovery && !state.announced[id] {
+			msg, err := p.discoveryConfig(state, id, value)
+			if err != nil {
+				log.Printf("failed to create discovery config for %s: %v", id, err)
+				continue
+			}
+			if p.client.Publish(msg) == nil {
+				state.announced[id] = true
+			}
+		}
+
+		p.publish(p.valueTopic(state, id), formatValue(value), p.retain)
+	}
+}
+
+// publish message and log errors
+func (p *publisher) publish(topic, payload string, retain bool) {
+	err := p.client.Publish(mqttMessage{
+		Topic:   topic,
+		Payload: []byte(payload),
+		Retain:  retain,
+	})
+	if err != nil && err != errNotConnected {
+		log.Printf("failed to publish %s: %v", topic, err)
+	}
+}
+
+// formatValue as MQTT payload
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// cachedDevice provides the values of a device (implemented by *sunny.Device)
+type cachedDevice interface {
+	SerialNumber() uint32
+	Address() *net.UDPAddr
+	IsEnergyMeter() bool
+	GetTimedValuesCtx(ctx context.Context) (map[sunny.ValueID]sunny.TimedValue, error)
+}
+
+// deviceEntry caches the values of a single device
+type deviceEntry struct {
+	device cachedDevice
+	serial uint32
+
+	maxAge  time.Duration
+	timeout time.Duration
+
+	// locked while values are requested -> concurrent requests wait for result
+	fetchMutex sync.Mutex
+
+	mutex   sync.RWMutex
+	values  map[sunny.ValueID]sunny.TimedValue
+	fetched time.Time
+	err     error
+	// time of last failed request
+	failed time.Time
+
+	// subscribers for energy meter updates
+	subscribers map[chan map[sunny.ValueID]sunny.TimedValue]struct{}
+}
+
+// newDeviceEntry creates a cache entry for the device
+// energy meters are read continuously in background
+func newDeviceEntry(device cachedDevice, maxAge, timeout time.Duration) *deviceEntry {
+	entry := &deviceEntry{
+		device:      device,
+		serial:      device.SerialNumber(),
+		maxAge:      maxAge,
+		timeout:     timeout,
+		subscribers: make(map[chan map[sunny.ValueID]sunny.TimedValue]struct{}),
+	}
+
+	if device.IsEnergyMeter() {
+		go entry.meterLoop()
+	}
+	return entry
+}
+
+// Values returns cached values or requests them from the device if outdated
+func (e *deviceEntry) Values() (map[sunny.ValueID]sunny.TimedValue, time.Time, error) {
+	if e.device.IsEnergyMeter() {
+		return e.cached()
+	}
+
+	if e.recent() {
+		return e.cached()
+	}
+
+	e.fetchMutex.Lock()
+	defer e.fetchMutex.Unlock()
+
+	// values or error may be updated while waiting for lock
+	if e.recent() {
+		return e.cached()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	values, err := e.device.GetTimedValuesCtx(ctx)
+	cancel()
+
+	e.update(values, err)
+	return e.cached()
+}
+
+// recent returns true if the result of the last request is not older
+// than maxAge (errors are cached too to prevent request floods)
+func (e *deviceEntry) recent() bool {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if e.err != nil {
+		return time.Since(e.failed) < e.maxAge
+	}
+	return e.values != nil && time.Since(e.fetched) < e.maxAge
+}
+
+// cached returns the values of last request
+func (e *deviceEntry) cached() (map[sunny.ValueID]sunny.TimedValue, time.Time, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if e.values == nil && e.err == nil {
+		return nil, time.Time{}, fmt.Errorf("no values received yet")
+	}
+	return e.values, e.fetched, e.err
+}
+
+// update cache with received values
+func (e *deviceEntry) update(values map[sunny.ValueID]sunny.TimedValue, err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.err = err
+	if err != nil {
+		e.failed = time.Now()
+		log.Printf("failed to get values of %d: %v", e.serial, err)
+		return
+	}
+	e.values = values
+	e.fetched = time.Now()
+
+	for ch := range e.subscribers {
+		select {
+		case ch <- values:
+		default:
+			// subscriber busy -> drop update
+		}
+	}
+}
+
+// Subscribe to energy meter updates
+func (e *deviceEntry) Subscribe() chan map[sunny.ValueID]sunny.TimedValue {
+	ch := make(chan map[sunny.ValueID]sunny.TimedValue, 1)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.subscribers[ch] = struct{}{}
+	return ch
+}
+
+// Unsubscribe from energy meter updates
+func (e *deviceEntry) Unsubscribe(ch chan map[sunny.ValueID]sunny.TimedValue) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	delete(e.subscribers, ch)
+}
+
+// meterLoop reads energy meter broadcasts continuously
+func (e *deviceEntry) meterLoop() {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+		values, err := e.device.GetTimedValuesCtx(ctx)
+		cancel()
+
+		e.update(values, err)
+		if err != nil {
+			time.Sleep(time.Second)
+		}
+	}
+}
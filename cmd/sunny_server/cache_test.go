@@ -0,0 +1,137 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// countingDevice counts requests of values
+type countingDevice struct {
+	mutex    sync.Mutex
+	requests int
+	err      error
+}
+
+func (d *countingDevice) SerialNumber() uint32 {
+	return 1
+}
+
+func (d *countingDevice) Address() *net.UDPAddr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+func (d *countingDevice) IsEnergyMeter() bool {
+	return false
+}
+
+func (d *countingDevice) GetTimedValuesCtx(ctx context.Context) (map[sunny.ValueID]sunny.TimedValue, error) {
+	// slow device -> concurrent requests wait for the result
+	time.Sleep(time.Millisecond * 20)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.requests++
+	if d.err != nil {
+		return nil, d.err
+	}
+	return map[sunny.ValueID]sunny.TimedValue{
+		sunny.ActivePowerPlus: {Value: 1000.0, Time: time.Now()},
+	}, nil
+}
+
+func (d *countingDevice) setError(err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.err = err
+}
+
+func (d *countingDevice) count() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.requests
+}
+
+// concurrentValues requests the values of the entry from multiple goroutines
+func concurrentValues(entry *deviceEntry, count int) []error {
+	var wg sync.WaitGroup
+	errs := make([]error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, errs[i] = entry.Values()
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestDeviceEntry_Values(t *testing.T) {
+	ass := assert.New(t)
+
+	device := &countingDevice{}
+	entry := newDeviceEntry(device, time.Millisecond*200, time.Second)
+
+	for _, err := range concurrentValues(entry, 10) {
+		ass.NoError(err)
+	}
+	ass.Equal(1, device.count())
+
+	values, _, err := entry.Values()
+	ass.NoError(err)
+	ass.Equal(1000.0, values[sunny.ActivePowerPlus].Value)
+	ass.Equal(1, device.count())
+
+	// outdated -> request again
+	time.Sleep(time.Millisecond * 200)
+	_, _, err = entry.Values()
+	ass.NoError(err)
+	ass.Equal(2, device.count())
+}
+
+func TestDeviceEntry_ValuesError(t *testing.T) {
+	ass := assert.New(t)
+
+	device := &countingDevice{err: fmt.Errorf("device offline")}
+	entry := newDeviceEntry(device, time.Millisecond*200, time.Second)
+
+	// errors are shared by waiting requests and cached until maxAge
+	for _, err := range concurrentValues(entry, 10) {
+		ass.Error(err)
+	}
+	ass.Equal(1, device.count())
+
+	_, _, err := entry.Values()
+	ass.Error(err)
+	ass.Equal(1, device.count())
+
+	// device is back after maxAge
+	device.setError(nil)
+	time.Sleep(time.Millisecond * 200)
+	for _, err := range concurrentValues(entry, 10) {
+		ass.NoError(err)
+	}
+	ass.Equal(2, device.count())
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+var inf = flag.String("inf", "", "Interface devices are connected to")
+var devices = flag.String("devices", "", "Comma separated list of device addresses (disables discovery)")
+var password = flag.String("password", "0000", "User password of the inverters")
+var listen = flag.String("listen", ":8080", "Address of the HTTP server")
+var maxAge = flag.Duration("cache", time.Second*5, "Maximum age of cached inverter values")
+var timeout = flag.Duration("timeout", time.Second*5, "Timeout of a single device request")
+var discoverInterval = flag.Duration("discover-interval", time.Minute*10, "Interval between device discoveries")
+
+func main() {
+	flag.Parse()
+
+	connection, err := sunny.NewConnection(*inf)
+	if err != nil {
+		log.Fatalf("failed to open connection: %v", err)
+	}
+
+	srv := newServer(*maxAge, *timeout)
+	if *devices != "" {
+		for _, address := range strings.Split(*devices, ",") {
+			device, err := connection.NewDevice(strings.TrimSpace(address), *password)
+			if err != nil {
+				log.Printf("skip device %s: %v", address, err)
+				continue
+			}
+			srv.addDevice(device)
+		}
+	} else {
+		go discoverLoop(connection, srv)
+	}
+
+	http.Handle("/devices", srv)
+	http.Handle("/devices/", srv)
+	log.Printf("listen on %s", *listen)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
+
+// discoverLoop searches for new devices periodically
+func discoverLoop(connection *sunny.Connection, srv *server) {
+	for {
+		devices := make(chan *sunny.Device, 10)
+		go func() {
+			for device := range devices {
+				srv.addDevice(device)
+			}
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		connection.DiscoverDevices(ctx, devices, *password)
+		cancel()
+		close(devices)
+
+		time.Sleep(*discoverInterval)
+	}
+}
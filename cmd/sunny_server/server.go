@@ -0,0 +1,274 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// deviceInfo returned by the device list
+type deviceInfo struct {
+	Serial      uint32 `json:"serial"`
+	Address     string `json:"address"`
+	EnergyMeter bool   `json:"energy_meter"`
+}
+
+// valueInfo returned for a single value
+type valueInfo struct {
+	Value       interface{} `json:"value"`
+	Unit        string      `json:"unit,omitempty"`
+	Description string      `json:"description"`
+	Time        *time.Time  `json:"time,omitempty"`
+}
+
+// valuesResponse with all values of a device
+type valuesResponse struct {
+	Serial  uint32               `json:"serial"`
+	Fetched time.Time            `json:"fetched"`
+	Values  map[string]valueInfo `json:"values"`
+}
+
+// server provides the HTTP API for all known devices
+type server struct {
+	maxAge  time.Duration
+	timeout time.Duration
+
+	mutex   sync.RWMutex
+	devices map[uint32]*deviceEntry
+}
+
+// newServer creates an empty server
+func newServer(maxAge, timeout time.Duration) *server {
+	return &server{
+		maxAge:  maxAge,
+		timeout: timeout,
+		devices: make(map[uint32]*deviceEntry),
+	}
+}
+
+// addDevice to the server if not already known
+func (s *server) addDevice(device *sunny.Device) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.devices[device.SerialNumber()]; ok {
+		device.Close() // already known -> release new instance
+		return
+	}
+	log.Printf("add device %d at %s", device.SerialNumber(), device.Address().IP)
+	s.devices[device.SerialNumber()] = newDeviceEntry(device, s.maxAge, s.timeout)
+}
+
+// getDevice by serial number
+func (s *server) getDevice(serial string) *deviceEntry {
+	number, err := strconv.ParseUint(serial, 10, 32)
+	if err != nil {
+		return nil
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.devices[uint32(number)]
+}
+
+// ServeHTTP routes the requests
+//
+//	GET /devices
+//	GET /devices/{serial}/values
+//	GET /devices/{serial}/values/{valueID}
+//	GET /devices/{serial}/events (energy meter only)
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "devices" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if len(parts) == 1 {
+		s.handleDevices(w)
+		return
+	}
+
+	entry := s.getDevice(parts[1])
+	if entry == nil {
+		writeError(w, http.StatusNotFound, "unknown device %s", parts[1])
+		return
+	}
+
+	switch {
+	case len(parts) == 3 && parts[2] == "values":
+		s.handleValues(w, entry)
+	case len(parts) == 4 && parts[2] == "values":
+		s.handleValue(w, entry, parts[3])
+	case len(parts) == 3 && parts[2] == "events":
+		s.handleEvents(w, r, entry)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// handleDevices returns the list of known devices
+func (s *server) handleDevices(w http.ResponseWriter) {
+	s.mutex.RLock()
+	devices := make([]deviceInfo, 0, len(s.devices))
+	for _, entry := range s.devices {
+		devices = append(devices, deviceInfo{
+			Serial:      entry.serial,
+			Address:     entry.device.Address().IP.String(),
+			EnergyMeter: entry.device.IsEnergyMeter(),
+		})
+	}
+	s.mutex.RUnlock()
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Serial < devices[j].Serial
+	})
+	writeJSON(w, devices)
+}
+
+// handleValues returns all values of a device
+func (s *server) handleValues(w http.ResponseWriter, entry *deviceEntry) {
+	values, fetched, err := entry.Values()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "failed to get values: %v", err)
+		return
+	}
+	writeJSON(w, newValuesResponse(entry.serial, fetched, values))
+}
+
+// handleValue returns a single value of a device
+func (s *server) handleValue(w http.ResponseWriter, entry *deviceEntry, name string) {
+	id, err := sunny.ValueIDString(name)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "unknown value %s", name)
+		return
+	}
+
+	values, _, err := entry.Values()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "failed to get values: %v", err)
+		return
+	}
+
+	value, ok := values[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "value %s not provided by device", id)
+		return
+	}
+	writeJSON(w, newValueInfo(id, value))
+}
+
+// handleEvents streams energy meter values as server-sent events
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request, entry *deviceEntry) {
+	if !entry.device.IsEnergyMeter() {
+		writeError(w, http.StatusBadRequest, "events are only available for energy meters")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	ch := entry.Subscribe()
+	defer entry.Unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case values := <-ch:
+			data, err := json.Marshal(newValuesResponse(entry.serial, time.Now(), values))
+			if err != nil {
+				log.Printf("failed to encode event: %v", err)
+				continue
+			}
+			_, err = fmt.Fprintf(w, "event: values\ndata: %s\n\n", data)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// newValuesResponse from device values
+func newValuesResponse(serial uint32, fetched time.Time, values map[sunny.ValueID]sunny.TimedValue) valuesResponse {
+	response := valuesResponse{
+		Serial:  serial,
+		Fetched: fetched,
+		Values:  make(map[string]valueInfo, len(values)),
+	}
+	for id, value := range values {
+		response.Values[id.String()] = newValueInfo(id, value)
+	}
+	return response
+}
+
+// newValueInfo from device value
+func newValueInfo(id sunny.ValueID, value sunny.TimedValue) valueInfo {
+	info := sunny.GetValueInfo(id)
+	v := valueInfo{
+		Value:       value.Value,
+		Unit:        info.Unit,
+		Description: info.Description,
+	}
+	if !value.Time.IsZero() {
+		t := value.Time
+		v.Time = &t
+	}
+	return v
+}
+
+// writeJSON response
+func writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(data)
+	if err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+// writeError response as JSON
+func writeError(w http.ResponseWriter, status int, format string, v ...interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"error": fmt.Sprintf(format, v...),
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+	"gitlab.com/bboehmke/sunny/modbus"
+	"gitlab.com/bboehmke/sunny/sunspec"
+)
+
+var inf = flag.String("inf", "", "Interface devices are connected to")
+var devices = flag.String("devices", "", "Comma separated list of device addresses (disables discovery)")
+var password = flag.String("password", "0000", "User password of the inverters")
+var listen = flag.String("listen", ":502", "Address of the Modbus TCP server")
+var interval = flag.Duration("interval", time.Second*5, "Interval between two device polls")
+var timeout = flag.Duration("timeout", time.Second*5, "Timeout of a single device poll")
+var firstUnit = flag.Uint("first-unit", 1, "Modbus unit ID of the first device (ordered by serial number)")
+
+func main() {
+	flag.Parse()
+
+	connection, err := sunny.NewConnection(*inf)
+	if err != nil {
+		log.Fatalf("failed to open connection: %v", err)
+	}
+
+	var deviceList []*sunny.Device
+	if *devices != "" {
+		for _, address := range strings.Split(*devices, ",") {
+			device, err := connection.NewDevice(strings.TrimSpace(address), *password)
+			if err != nil {
+				log.Printf("skip device %s: %v", address, err)
+				continue
+			}
+			deviceList = append(deviceList, device)
+		}
+	} else {
+		deviceList = connection.SimpleDiscoverDevices(*password)
+	}
+	if len(deviceList) == 0 {
+		log.Fatalf("no devices found")
+	}
+	if int(*firstUnit)+len(deviceList)-1 > 247 || *firstUnit == 0 {
+		log.Fatalf("unit IDs %d-%d out of range 1-247", *firstUnit, int(*firstUnit)+len(deviceList)-1)
+	}
+
+	// stable unit IDs -> order devices by serial number
+	sort.Slice(deviceList, func(i, j int) bool {
+		return deviceList[i].SerialNumber() < deviceList[j].SerialNumber()
+	})
+
+	gateway := sunspec.NewGateway(*timeout)
+	for i, device := range deviceList {
+		unit := uint8(int(*firstUnit) + i)
+		log.Printf("provide device %d at %s as unit %d", device.SerialNumber(), device.Address().IP, unit)
+		err = gateway.AddDevice(unit, device)
+		if err != nil {
+			log.Fatalf("failed to add device: %v", err)
+		}
+	}
+	go gateway.Run(context.Background(), *interval)
+
+	server := &modbus.Server{Handler: gateway}
+	log.Printf("listen on %s", *listen)
+	log.Fatal(server.ListenAndServe(*listen))
+}
@@ -15,11 +15,13 @@
 package sunny
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"sync"
 
 	"gitlab.com/bboehmke/sunny/proto"
+	"gitlab.com/bboehmke/sunny/proto/net2"
 )
 
 const listenAddress = "239.12.255.254:9522"
@@ -29,10 +31,24 @@ var connections = make(map[string]*Connection)
 
 // Connection for communication with devices
 type Connection struct {
+	// name of interface (empty for default interface)
+	inf string
+	// identity used as source of requests
+	identity net2.DeviceId
+	// policy for requests of devices
+	policy Policy
+	// capabilities of devices
+	capabilities *CapabilityCache
+	// mutex for policy and capabilities
+	settingsMutex sync.RWMutex
 	// multicast address
 	address *net.UDPAddr
 	// multicast socket
-	socket *net.UDPConn
+	socket transport
+	// recorder of sent and received packets (nil if disabled)
+	recorder *Recorder
+	// unicast connections are owned by a single device
+	unicast bool
 
 	// buffer for received packet
 	receiverMutex    sync.RWMutex
@@ -40,20 +56,48 @@ type Connection struct {
 
 	// interface for device discovery
 	discoverMutex    sync.RWMutex
-	discoverChannels []chan string
+	discoverChannels []chan discoveryResult
+
+	// responders for discovery requests
+	responderMutex sync.RWMutex
+	responders     []*Responder
+}
+
+// discoveryResult of a device found by discovery
+type discoveryResult struct {
+	// IP address advertised by the device
+	ip string
+	// energy meters are detected by their broadcasts
+	energyMeter bool
+	// id of device (only known for energy meters and unicast ping responses)
+	id net2.DeviceId
 }
 
 // NewConnection creates a new Connection object and starts listening
+// The identity of the local system is derived from the first IPv4 address
+// (see net2.LocalDeviceId).
 func NewConnection(inf string) (*Connection, error) {
+	return NewConnectionWithIdentity(inf, *net2.LocalDeviceId())
+}
+
+// NewConnectionWithIdentity creates a new Connection object that uses the
+// given identity as source of requests. Multiple collectors can communicate
+// with the same device concurrently if they use different identities.
+func NewConnectionWithIdentity(inf string, identity net2.DeviceId) (*Connection, error) {
 	connectionMutex.Lock()
 	defer connectionMutex.Unlock()
 
 	// connection already known
-	if c, ok := connections[inf]; ok {
+	key := connectionKey(inf, identity)
+	if c, ok := connections[key]; ok {
 		return c, nil
 	}
 
 	conn := Connection{
+		inf:              inf,
+		identity:         identity,
+		policy:           DefaultPolicy,
+		capabilities:     newMemoryCapabilityCache(),
 		receiverChannels: make(map[string][]chan *proto.Packet),
 	}
 
@@ -72,33 +116,121 @@ func NewConnection(inf string) (*Connection, error) {
 		}
 	}
 
-	conn.socket, err = net.ListenMulticastUDP("udp", listenInterface, conn.address)
+	socket, err := net.ListenMulticastUDP("udp", listenInterface, conn.address)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create connection: %w", err)
 	}
 
-	err = conn.socket.SetReadBuffer(2048)
+	err = socket.SetReadBuffer(2048)
 	if err != nil {
 		return nil, err
 	}
+	conn.socket = socket
 
 	go conn.listenLoop()
 
-	connections[inf] = &conn
+	connections[key] = &conn
+	return &conn, nil
+}
+
+// connectionKey of a multicast connection in the connection cache
+func connectionKey(inf string, identity net2.DeviceId) string {
+	return fmt.Sprintf("%s/%d:%d", inf, identity.SusyID, identity.SerialNumber)
+}
+
+// Interface name of the connection (empty for default interface)
+func (c *Connection) Interface() string {
+	return c.inf
+}
+
+// Identity used as source of requests
+func (c *Connection) Identity() net2.DeviceId {
+	return c.identity
+}
+
+// SetPolicy for all devices of this connection without an own policy
+func (c *Connection) SetPolicy(policy Policy) {
+	c.settingsMutex.Lock()
+	c.policy = policy.withDefaults()
+	c.settingsMutex.Unlock()
+}
+
+// Policy for devices of this connection
+func (c *Connection) Policy() Policy {
+	c.settingsMutex.RLock()
+	defer c.settingsMutex.RUnlock()
+	return c.policy
+}
+
+// SetCapabilityCache for all devices of this connection
+// (e.g. to persist capabilities with NewCapabilityCache)
+func (c *Connection) SetCapabilityCache(cache *CapabilityCache) {
+	c.settingsMutex.Lock()
+	c.capabilities = cache
+	c.settingsMutex.Unlock()
+}
+
+// CapabilityCache of devices of this connection
+func (c *Connection) CapabilityCache() *CapabilityCache {
+	c.settingsMutex.RLock()
+	defer c.settingsMutex.RUnlock()
+	return c.capabilities
+}
+
+// newUnicastConnection with an own socket bound to an ephemeral port
+func newUnicastConnection(identity net2.DeviceId) (*Connection, error) {
+	conn := Connection{
+		identity:         identity,
+		policy:           DefaultPolicy,
+		capabilities:     newMemoryCapabilityCache(),
+		receiverChannels: make(map[string][]chan *proto.Packet),
+		unicast:          true,
+	}
+
+	socket, err := net.ListenUDP("udp", &net.UDPAddr{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create unicast connection: %w", err)
+	}
+	conn.socket = socket
+
+	go conn.listenLoop()
 	return &conn, nil
 }
 
+// close socket of connection (only used for unicast connections)
+func (c *Connection) close() {
+	_ = c.socket.Close()
+}
+
+// closeMulticast removes a multicast connection from the connection cache
+// and closes its socket
+func (c *Connection) closeMulticast() {
+	connectionMutex.Lock()
+	key := connectionKey(c.inf, c.identity)
+	if connections[key] == c {
+		delete(connections, key)
+	}
+	connectionMutex.Unlock()
+
+	c.close()
+}
+
 // listenLoop for received packets
 func (c *Connection) listenLoop() {
 	b := make([]byte, 2048)
 
 	for c.socket != nil {
 		n, src, err := c.socket.ReadFromUDP(b)
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
 		if err != nil {
 			// failed to read from udp -> retry
 			continue
 		}
 
+		c.record(PacketReceived, src, b[:n])
+
 		srcIP := src.IP.String()
 		var pack proto.Packet
 		err = pack.Read(b[:n])
@@ -109,7 +241,8 @@ func (c *Connection) listenLoop() {
 		}
 		Log.Printf("recv %s: [%s]", srcIP, pack)
 
-		c.handleDiscovered(srcIP)
+		c.handleDiscoveryRequest(src, &pack)
+		c.handleDiscovered(srcIP, &pack)
 		c.handlePackets(srcIP, &pack)
 	}
 }
@@ -146,30 +279,74 @@ func (c *Connection) unregisterReceiver(srcIp string, ch chan *proto.Packet) {
 		return // IP not in in list -> no channel to unregister
 	}
 
-	c.receiverChannels[srcIp] = make([]chan *proto.Packet, len(receivers))
-	for i, receiver := range receivers {
+	c.receiverChannels[srcIp] = make([]chan *proto.Packet, 0, len(receivers))
+	for _, receiver := range receivers {
 		if receiver != ch {
-			c.receiverChannels[srcIp][i] = receiver
+			c.receiverChannels[srcIp] = append(c.receiverChannels[srcIp], receiver)
+		}
+	}
+	if len(c.receiverChannels[srcIp]) == 0 {
+		delete(c.receiverChannels, srcIp)
+	}
+}
+
+// parseDiscovery checks if the packet is a discovery response, an energy
+// meter broadcast or (on unicast connections) a ping response of an inverter
+func (c *Connection) parseDiscovery(srcIp string, packet *proto.Packet) (discoveryResult, bool) {
+	if ip := packet.DiscoveryIP(); ip != nil {
+		return discoveryResult{ip: ip.String()}, true
+	}
+
+	entry, ok := packet.GetEntry(proto.SmaNet2PacketEntryTag).(*proto.SmaNet2PacketEntry)
+	if !ok {
+		return discoveryResult{}, false
+	}
+
+	switch content := entry.Content.(type) {
+	case *net2.EnergyMeterPacket:
+		return discoveryResult{
+			ip:          srcIp,
+			energyMeter: true,
+			id:          content.Id,
+		}, true
+
+	case *net2.DeviceData:
+		// only responses to sweep requests are discovery responses
+		if c.unicast {
+			return discoveryResult{
+				ip: srcIp,
+				id: content.Source,
+			}, true
 		}
 	}
+	return discoveryResult{}, false
 }
 
-// handleDiscovered devices and forward IP to registered channels
-func (c *Connection) handleDiscovered(srcIp string) {
+// handleDiscovered devices and forward result to registered channels
+func (c *Connection) handleDiscovered(srcIp string, packet *proto.Packet) {
 	c.discoverMutex.RLock()
 	defer c.discoverMutex.RUnlock()
 
+	if len(c.discoverChannels) == 0 {
+		return
+	}
+
+	result, ok := c.parseDiscovery(srcIp, packet)
+	if !ok {
+		return
+	}
+
 	for _, ch := range c.discoverChannels {
 		select {
-		case ch <- srcIp:
+		case ch <- result:
 		default:
 			// channel for received packets busy -> drop packet
 		}
 	}
 }
 
-// registerDiscoverer channel to receive source IP of received device packages
-func (c *Connection) registerDiscoverer(ch chan string) {
+// registerDiscoverer channel to receive discovered devices
+func (c *Connection) registerDiscoverer(ch chan discoveryResult) {
 	c.discoverMutex.Lock()
 	defer c.discoverMutex.Unlock()
 
@@ -177,15 +354,15 @@ func (c *Connection) registerDiscoverer(ch chan string) {
 }
 
 // unregisterDiscoverer channel
-func (c *Connection) unregisterDiscoverer(ch chan string) {
+func (c *Connection) unregisterDiscoverer(ch chan discoveryResult) {
 	c.discoverMutex.Lock()
 	defer c.discoverMutex.Unlock()
 
 	discoverChannels := c.discoverChannels
-	c.discoverChannels = make([]chan string, len(discoverChannels))
-	for i, entry := range discoverChannels {
+	c.discoverChannels = make([]chan discoveryResult, 0, len(discoverChannels))
+	for _, entry := range discoverChannels {
 		if entry != ch {
-			c.discoverChannels[i] = entry
+			c.discoverChannels = append(c.discoverChannels, entry)
 		}
 	}
 }
@@ -193,9 +370,16 @@ func (c *Connection) unregisterDiscoverer(ch chan string) {
 // sendPacket to the given address
 func (c *Connection) sendPacket(address *net.UDPAddr, packet *proto.Packet) error {
 	Log.Printf("send %s: [%s]", address.IP.String(), packet)
-	_, err := c.socket.WriteToUDP(packet.Bytes(), address)
+	err := c.write(packet.Bytes(), address)
 	if err != nil {
 		return fmt.Errorf("send: %w", err)
 	}
 	return nil
 }
+
+// write data to the given address
+func (c *Connection) write(data []byte, address *net.UDPAddr) error {
+	c.record(PacketSent, address, data)
+	_, err := c.socket.WriteToUDP(data, address)
+	return err
+}
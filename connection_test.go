@@ -0,0 +1,95 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny/proto"
+	"gitlab.com/bboehmke/sunny/proto/net2"
+)
+
+// net2Packet containing the given content
+func net2Packet(content proto.PacketEntry) *proto.Packet {
+	var pack proto.Packet
+	pack.AddEntry(&proto.GroupPacketEntry{
+		Group: 0x00000001,
+	})
+	pack.AddEntry(content)
+	return &pack
+}
+
+func TestConnection_ParseDiscovery(t *testing.T) {
+	ass := assert.New(t)
+
+	multicast := &Connection{}
+	unicast := &Connection{unicast: true}
+	inverterID := net2.DeviceId{SusyID: 0x1234, SerialNumber: 2000000001}
+	meterID := net2.DeviceId{SusyID: 270, SerialNumber: 3000000001}
+
+	response := net2.NewDeviceData(0xa1)
+	response.Source = inverterID
+	pingResponse := net2Packet(&proto.SmaNet2PacketEntry{Content: response})
+	meter := net2Packet(&proto.SmaNet2PacketEntry{Content: &net2.EnergyMeterPacket{Id: meterID}})
+
+	tests := []struct {
+		conn   *Connection
+		packet *proto.Packet
+		result discoveryResult
+		ok     bool
+	}{
+		// discovery responses contain the IP of the device
+		{multicast, proto.NewDiscoveryResponse(net.IPv4(192, 168, 1, 20)),
+			discoveryResult{ip: "192.168.1.20"}, true},
+		{unicast, proto.NewDiscoveryResponse(net.IPv4(192, 168, 1, 20)),
+			discoveryResult{ip: "192.168.1.20"}, true},
+		// energy meter broadcasts
+		{multicast, meter, discoveryResult{ip: "192.168.1.10", energyMeter: true, id: meterID}, true},
+		{unicast, meter, discoveryResult{ip: "192.168.1.10", energyMeter: true, id: meterID}, true},
+		// device data is only a ping response on unicast connections
+		{multicast, pingResponse, discoveryResult{}, false},
+		{unicast, pingResponse, discoveryResult{ip: "192.168.1.10", id: inverterID}, true},
+		// discovery requests are ignored
+		{unicast, proto.NewDiscoveryRequest(), discoveryResult{}, false},
+	}
+	for i, test := range tests {
+		result, ok := test.conn.parseDiscovery("192.168.1.10", test.packet)
+		ass.Equal(test.ok, ok, "test %d", i)
+		ass.Equal(test.result, result, "test %d", i)
+	}
+}
+
+func TestConnection_HandleDiscovered(t *testing.T) {
+	ass := assert.New(t)
+
+	conn := &Connection{unicast: true}
+	ch := make(chan discoveryResult, 1)
+	conn.registerDiscoverer(ch)
+
+	response := net2.NewDeviceData(0xa1)
+	response.Source = net2.DeviceId{SusyID: 0x1234, SerialNumber: 2000000001}
+	conn.handleDiscovered("192.168.1.10", net2Packet(&proto.SmaNet2PacketEntry{Content: response}))
+	if ass.Len(ch, 1) {
+		ass.Equal(discoveryResult{ip: "192.168.1.10", id: response.Source}, <-ch)
+	}
+
+	// unregistered -> no results
+	conn.unregisterDiscoverer(ch)
+	conn.handleDiscovered("192.168.1.10", net2Packet(&proto.SmaNet2PacketEntry{Content: response}))
+	ass.Len(ch, 0)
+}
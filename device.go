@@ -16,8 +16,11 @@ package sunny
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
+	"sort"
+	"sync"
 	"time"
 
 	"gitlab.com/bboehmke/sunny/proto"
@@ -25,11 +28,28 @@ import (
 )
 
 // Device instance for communication with inverter and energy meter
+//
+// A Device is safe for concurrent use by multiple goroutines. Operations on
+// inverters that require a login (GetValue, GetValues, SetValue, ...) are
+// serialized per device so the session of one operation is never closed by
+// another one. Concurrent calls on energy meters share the next broadcast.
 type Device struct {
 	// Address of inverter or energy meter
 	address *net.UDPAddr
 	// password for inverter communication
 	password string
+	// policy of device (nil -> policy of connection)
+	policy *Policy
+	// capabilities of device (nil -> cache of connection)
+	capabilities *CapabilityCache
+	// info of device (nil if not read yet)
+	info *DeviceInfo
+	// parameters that can be written (nil -> inverterParameters)
+	parameters map[ValueID]Version
+	// mutex for password, policy, capabilities and info
+	mutex sync.RWMutex
+	// sessionMutex serializes login, requests and logout of inverters
+	sessionMutex sync.Mutex
 
 	// Connection instance for communication
 	conn *Connection
@@ -40,14 +60,21 @@ type Device struct {
 
 	// receiver channel for received package with IP of this device
 	receiver chan *proto.Packet
+	// mux routes received packages to the pending requests
+	mux *requestMux
+
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-// NewDevice creates a new device instance
-func (c *Connection) NewDevice(address, password string) (*Device, error) {
+// newDevice creates a device instance and registers the receiver channel
+func (c *Connection) newDevice(address, password string) (*Device, error) {
 	device := Device{
 		conn:     c,
 		password: password,
-		receiver: make(chan *proto.Packet, 2),
+		receiver: make(chan *proto.Packet, 16),
+		mux:      newRequestMux(c.identity),
+		done:     make(chan struct{}),
 	}
 
 	var err error
@@ -55,64 +82,268 @@ func (c *Connection) NewDevice(address, password string) (*Device, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve udp address: %w", err)
 	}
+
+	// register receiver channel for this device (resolved IP in case of DNS)
+	c.registerReceiver(device.address.IP.String(), device.receiver)
+	go device.mux.run(device.receiver, device.done)
+	return &device, nil
+}
+
+// newDiscoveredDevice creates a device instance from a discovery result
+// Note: energy meters are known from their broadcasts and need no ping
+func (c *Connection) newDiscoveredDevice(result discoveryResult, password string) (*Device, error) {
+	if !result.energyMeter {
+		return c.NewDevice(result.ip, password)
+	}
+
+	device, err := c.newDevice(result.ip, password)
+	if err != nil {
+		return nil, err
+	}
+	Log.Printf("new energy meter at %s - Serial=%d", result.ip, result.id.SerialNumber)
+	device.energyMeter = true
+	device.id = result.id
+	return device, nil
+}
+
+// NewDevice creates a new device instance
+func (c *Connection) NewDevice(address, password string) (*Device, error) {
+	return c.NewDeviceCtx(context.Background(), address, password)
+}
+
+// NewDeviceCtx creates a new device instance
+// The ping of the device is limited by the context and the policy of the
+// connection (can be overridden with WithPolicy).
+func (c *Connection) NewDeviceCtx(ctx context.Context, address, password string) (*Device, error) {
+	device, err := c.newDevice(address, password)
+	if err != nil {
+		return nil, err
+	}
 	// update address with resolved IP (in case of DNS)
 	address = device.address.IP.String()
 
-	// register receiver channel for this device
-	c.registerReceiver(address, device.receiver)
-
 	// send ping
 	pingData := net2.NewDeviceData(0xa0)
 	pingData.AddParameter(0)
 	pingData.AddParameter(0)
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	// energy meters do not respond but broadcast their values
+	response := device.mux.register(pingData)
+	defer device.mux.unregister(pingData)
+	meter := device.mux.subscribeMeter()
+	defer device.mux.unsubscribeMeter(meter)
+
+	policy := device.policyFor(ctx)
+	ctx, cancel := policy.withTimeout(ctx)
 	defer cancel()
-	for {
-		// check for timeout
-		select {
-		case <-ctx.Done():
-			return nil, fmt.Errorf("no ping response for %s", address)
-		default:
+	for retry := 0; ; retry++ {
+		if policy.MaxRetries > 0 && retry > policy.MaxRetries {
+			device.Close()
+			return nil, fmt.Errorf("%w: no ping response for %s", ErrTimeout, address)
 		}
 
 		err = device.sendDeviceData(pingData)
 		if err != nil {
 			Log.Printf("failed to send ping request for %s", address)
+			device.Close()
 			return nil, err
 		}
 
-		// wait for receive
-		receiveCtx, receiveCancel := context.WithTimeout(ctx, time.Millisecond*500)
-		net2Entry, err := device.readNet2(receiveCtx)
-		receiveCancel()
-		if err != nil {
-			continue
-		}
+		timer := time.NewTimer(policy.resendInterval(retry))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			device.Close()
+			return nil, fmt.Errorf("%w: no ping response for %s", ErrTimeout, address)
 
-		switch c := net2Entry.Content.(type) {
-		case *net2.EnergyMeterPacket:
-			Log.Printf("new energy meter at %s - Serial=%d", address, c.Id.SerialNumber)
+		case packet := <-meter:
+			timer.Stop()
+			Log.Printf("new energy meter at %s - Serial=%d", address, packet.Id.SerialNumber)
 			device.energyMeter = true
-			device.id = c.Id
-			return &device, nil
+			device.id = packet.Id
+			return device, nil
 
-		case *net2.DeviceData:
-			Log.Printf("new inverter at %s - Serial=%d", address, c.Source.SerialNumber)
-			device.id = c.Source
-			return &device, nil
+		case data := <-response:
+			timer.Stop()
+			Log.Printf("new inverter at %s - Serial=%d", address, data.Source.SerialNumber)
+			device.id = data.Source
+			return device, nil
+
+		case <-timer.C:
 		}
 	}
 }
 
+// NewUnicastDevice creates a new device instance with an own UDP socket that
+// sends requests directly to the device and receives the responses on an
+// ephemeral port. This allows communication with inverters in routed networks
+// without multicast.
+// Note: energy meters only send multicast packets and are not supported
+func NewUnicastDevice(address, password string) (*Device, error) {
+	return NewUnicastDeviceCtx(context.Background(), address, password)
+}
+
+// NewUnicastDeviceCtx creates a new device instance in unicast mode
+// (see NewUnicastDevice) with a context for the ping of the device
+func NewUnicastDeviceCtx(ctx context.Context, address, password string) (*Device, error) {
+	return NewUnicastDeviceWithIdentity(ctx, address, password, *net2.LocalDeviceId())
+}
+
+// NewUnicastDeviceWithIdentity creates a new device instance in unicast mode
+// (see NewUnicastDevice) that uses the given identity as source of requests
+// (see NewConnectionWithIdentity)
+func NewUnicastDeviceWithIdentity(ctx context.Context, address, password string, identity net2.DeviceId) (*Device, error) {
+	conn, err := newUnicastConnection(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	device, err := conn.NewDeviceCtx(ctx, address, password)
+	if err != nil {
+		conn.close()
+		return nil, err
+	}
+	return device, nil
+}
+
 // Close unregister receiver channel
 func (d *Device) Close() {
-	d.conn.unregisterReceiver(d.address.IP.String(), d.receiver)
+	d.closeOnce.Do(func() {
+		d.conn.unregisterReceiver(d.address.IP.String(), d.receiver)
+		close(d.done)
+		if d.conn.unicast {
+			d.conn.close()
+		}
+	})
 }
 
 // SetPassword for device communication
 func (d *Device) SetPassword(pw string) {
+	d.mutex.Lock()
 	d.password = pw
+	d.mutex.Unlock()
+}
+
+// getPassword for device communication
+func (d *Device) getPassword() string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.password
+}
+
+// SetPolicy for requests of this device
+func (d *Device) SetPolicy(policy Policy) {
+	policy = policy.withDefaults()
+	d.mutex.Lock()
+	d.policy = &policy
+	d.mutex.Unlock()
+}
+
+// Policy for requests of this device
+func (d *Device) Policy() Policy {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	if d.policy != nil {
+		return *d.policy
+	}
+	return d.conn.Policy()
+}
+
+// policyFor operation with context (see WithPolicy)
+func (d *Device) policyFor(ctx context.Context) Policy {
+	if policy, ok := policyFromContext(ctx); ok {
+		return policy.withDefaults()
+	}
+	return d.Policy()
+}
+
+// SetCapabilityCache used for this device
+func (d *Device) SetCapabilityCache(cache *CapabilityCache) {
+	d.mutex.Lock()
+	d.capabilities = cache
+	d.mutex.Unlock()
+}
+
+// capabilityCache of device or connection
+func (d *Device) capabilityCache() *CapabilityCache {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	if d.capabilities != nil {
+		return d.capabilities
+	}
+	return d.conn.CapabilityCache()
+}
+
+// requestSupported returns false if the request is known to be unsupported
+func (d *Device) requestSupported(def InverterValuesDef) bool {
+	supported, known := d.capabilityCache().supported(d.id.SerialNumber, newRequestGroup(def))
+	return supported || !known
+}
+
+// requestValuesCached requests values and updates the capability cache
+func (d *Device) requestValuesCached(ctx context.Context, def InverterValuesDef) (map[ValueID]TimedValue, error) {
+	values, err := d.requestValues(ctx, def)
+	if err == nil {
+		ids := make([]ValueID, 0, len(values))
+		for id := range values {
+			ids = append(ids, id)
+		}
+		d.capabilityCache().markSupported(d.id.SerialNumber, newRequestGroup(def), ids)
+	} else if errors.Is(err, ErrNotSupported) {
+		d.capabilityCache().markUnsupported(d.id.SerialNumber, newRequestGroup(def))
+	}
+	return values, err
+}
+
+// SupportedValues of device
+// The supported requests of inverters are probed on the first call (or
+// the first GetValues) and stored in the capability cache. Only values
+// that were returned by the inverter are reported.
+func (d *Device) SupportedValues() ([]ValueID, error) {
+	return d.SupportedValuesCtx(context.Background())
+}
+
+// SupportedValuesCtx of device (see SupportedValues)
+func (d *Device) SupportedValuesCtx(ctx context.Context) ([]ValueID, error) {
+	var ids []ValueID
+	if d.energyMeter {
+		values, err := d.GetTimedValuesCtx(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for id := range values {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool {
+			return ids[i] < ids[j]
+		})
+		return ids, nil
+	}
+
+	// probe requests that are not known (unsupported requests have to
+	// fail multiple times before they are known)
+	cache := d.capabilityCache()
+	for i := 0; i < unsupportedStrikes && d.unknownRequests(); i++ {
+		result, err := d.GetValuesResultCtx(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !result.Complete() {
+			return nil, result.Err()
+		}
+	}
+	return cache.values(d.id.SerialNumber), nil
+}
+
+// unknownRequests returns true if any request was not probed yet
+func (d *Device) unknownRequests() bool {
+	cache := d.capabilityCache()
+	for _, def := range getAllInverterRequests() {
+		if _, known := cache.supported(d.id.SerialNumber, newRequestGroup(def)); !known {
+			return true
+		}
+	}
+	return false
 }
 
 // SerialNumber returns the serial number of the device
@@ -120,6 +351,11 @@ func (d *Device) SerialNumber() uint32 {
 	return d.id.SerialNumber
 }
 
+// ID returns the SusyID and serial number of the device
+func (d *Device) ID() net2.DeviceId {
+	return d.id
+}
+
 // Address returns the address of the device
 func (d *Device) Address() *net.UDPAddr {
 	return d.address
@@ -131,16 +367,20 @@ func (d *Device) IsEnergyMeter() bool {
 }
 
 // GetValue from inverter and returns nil if value does not exist
+// If the value is not supported by the device ErrNotSupported is returned.
 // Note: to request multiple values use GetValues
 func (d *Device) GetValue(id ValueID) (interface{}, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
-	defer cancel()
-	return d.GetValueCtx(ctx, id)
+	return d.GetValueCtx(context.Background(), id)
 }
 
 // GetValueCtx from inverter and returns nil if value does not exist
+// If the value is not supported by the device ErrNotSupported is returned.
 // Note: to request multiple values use GetValues
 func (d *Device) GetValueCtx(ctx context.Context, id ValueID) (interface{}, error) {
+	policy := d.policyFor(ctx)
+	ctx, cancel := policy.withTimeout(ctx)
+	defer cancel()
+
 	if d.energyMeter {
 		// handle some fixed energy meter values
 		if id == DeviceClass {
@@ -158,89 +398,172 @@ func (d *Device) GetValueCtx(ctx context.Context, id ValueID) (interface{}, erro
 		return values[id], nil
 	}
 
-	// clear queue -> get fresh data
-	d.clearReceiver()
+	def, ok := inverterValueMap[id]
+	if !ok || !d.requestSupported(def) {
+		return nil, fmt.Errorf("%w: value %s", ErrNotSupported, id)
+	}
+
+	d.sessionMutex.Lock()
+	defer d.sessionMutex.Unlock()
 
-	err := d.loginRetry(ctx, 3)
+	err := d.loginRetry(ctx, policy.LoginRetries)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
+	defer d.logout()
 
-	values, err := d.requestValues(ctx, getInverterRequest(id))
+	values, err := d.requestValuesCached(ctx, def)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-
-	d.logout()
-	return values[id], nil
+	return values[id].Value, nil
 }
 
 // GetValues from device
 func (d *Device) GetValues() (map[ValueID]interface{}, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
-	defer cancel()
-	return d.GetValuesCtx(ctx)
+	return d.GetValuesCtx(context.Background())
 }
 
 // GetValuesCtx from device
 func (d *Device) GetValuesCtx(ctx context.Context) (map[ValueID]interface{}, error) {
-	// clear queue -> get fresh data
-	d.clearReceiver()
+	values, err := d.GetTimedValuesCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return stripTimes(values), nil
+}
 
-	if d.energyMeter {
-		for {
-			// check for timeout
-			select {
-			case <-ctx.Done():
-				return nil, fmt.Errorf("energy meter does not respond")
-			default:
-			}
+// GetTimedValues from device with the time the values were measured
+func (d *Device) GetTimedValues() (map[ValueID]TimedValue, error) {
+	return d.GetTimedValuesCtx(context.Background())
+}
 
-			// wait for received packet
-			net2Entry, err := d.readNet2(ctx)
-			if err != nil {
-				continue
-			}
+// GetTimedValuesCtx from device with the time the values were measured
+// Values of failed requests are missing in the result. An error is only
+// returned if no request succeeded (see GetValuesResultCtx for details).
+// Note: energy meters do not provide an absolute time -> time of reception is used
+func (d *Device) GetTimedValuesCtx(ctx context.Context) (map[ValueID]TimedValue, error) {
+	result, err := d.GetValuesResultCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Succeeded) == 0 && !result.Complete() {
+		return nil, result.Err()
+	}
+	return result.Values, nil
+}
+
+// GetValuesResult from device with the state of every request
+func (d *Device) GetValuesResult() (*ValuesResult, error) {
+	return d.GetValuesResultCtx(context.Background())
+}
+
+// GetValuesResultCtx from device with the state of every request
+// An error is only returned if the device could not be accessed at all
+// (e.g. login failed). Failures of single requests are part of the result.
+func (d *Device) GetValuesResultCtx(ctx context.Context) (*ValuesResult, error) {
+	policy := d.policyFor(ctx)
+	ctx, cancel := policy.withTimeout(ctx)
+	defer cancel()
 
-			packet, ok := net2Entry.Content.(*net2.EnergyMeterPacket)
-			if !ok {
-				continue
+	result := newValuesResult()
+	if d.energyMeter {
+		// wait for next broadcast -> get fresh data
+		meter := d.mux.subscribeMeter()
+		defer d.mux.unsubscribeMeter(meter)
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.Canceled {
+				return nil, ctx.Err()
 			}
-			return convertEnergyMeterValues(packet.GetValues()), nil
+			return nil, fmt.Errorf("%w: energy meter at %s", ErrTimeout, d.address.IP)
+		case packet := <-meter:
+			result.Values = convertEnergyMeterValues(packet.GetValues(), time.Now())
+			return result, nil
 		}
 	}
 
+	d.sessionMutex.Lock()
+	defer d.sessionMutex.Unlock()
+
 	// login to device
-	err := d.loginRetry(ctx, 3)
+	err := d.loginRetry(ctx, policy.LoginRetries)
 	if err != nil {
 		return nil, err
 	}
+	defer d.logout()
 
-	// request all values and join to one map
-	valuesMap := make(map[ValueID]interface{})
+	// request all values and join to one result
 	for _, def := range getAllInverterRequests() {
-		values, err := d.requestValues(ctx, def)
-		if err != nil {
-			Log.Printf("failed to get values for %s: %v", d.address, err)
-			continue
-		}
-		if values == nil {
+		// skip requests known to be unsupported
+		if !d.requestSupported(def) {
+			result.Unsupported = append(result.Unsupported, def)
 			continue
 		}
-		for id, value := range values {
-			valuesMap[id] = value
+
+		values, err := d.requestValuesCached(ctx, def)
+		if err != nil && !errors.Is(err, ErrNotSupported) {
+			Log.Printf("failed to get values for %s: %v", d.address, err)
 		}
+		result.add(def, values, err)
 	}
+	return result, nil
+}
 
-	// logout
-	d.logout()
+// SetValue on inverter
+// Note: only values of inverterParameters can be written
+func (d *Device) SetValue(id ValueID, value interface{}) error {
+	return d.SetValueCtx(context.Background(), id, value)
+}
+
+// SetValueCtx on inverter
+// Note: only values of inverterParameters can be written
+func (d *Device) SetValueCtx(ctx context.Context, id ValueID, value interface{}) error {
+	policy := d.policyFor(ctx)
+	ctx, cancel := policy.withTimeout(ctx)
+	defer cancel()
+
+	if d.energyMeter {
+		return fmt.Errorf("%w: energy meter does not support writing of values", ErrNotSupported)
+	}
+	parameters := d.parameters
+	if parameters == nil {
+		parameters = inverterParameters
+	}
+	minFirmware, ok := parameters[id]
+	if !ok {
+		return fmt.Errorf("%w: value %s can not be written", ErrNotSupported, id)
+	}
+	err := d.requireFirmware(ctx, minFirmware)
+	if err != nil {
+		return err
+	}
+
+	def := getInverterRequest(id)
+	responseValue, err := encodeInverterValue(def, value)
+	if err != nil {
+		return err
+	}
 
-	return valuesMap, nil
+	d.sessionMutex.Lock()
+	defer d.sessionMutex.Unlock()
+
+	err = d.loginRetry(ctx, policy.LoginRetries)
+	if err != nil {
+		return err
+	}
+
+	err = d.writeValue(ctx, def, responseValue)
+	d.logout()
+	return err
 }
 
+// loginRetry until login succeeds or is rejected by the device
 func (d *Device) loginRetry(ctx context.Context, trys int) (err error) {
 	for i := 0; i < trys; i++ {
-		if err = d.login(ctx); err == nil {
+		err = d.login(ctx)
+		if err == nil || errors.Is(err, ErrAuth) {
 			return
 		}
 	}
@@ -261,7 +584,7 @@ func (d *Device) login(ctx context.Context) error {
 	loginData.AddParameter(0)
 
 	// "encrypt" user password
-	pass := []byte(d.password)
+	pass := []byte(d.getPassword())
 	encryptKey := byte(0x88) // 0xBB for installer
 
 	passwordData := make([]byte, 12)
@@ -274,13 +597,13 @@ func (d *Device) login(ctx context.Context) error {
 	}
 	loginData.Data = passwordData
 
-	response, err := d.sendDeviceDataResponse(loginData, time.Millisecond*500, ctx)
+	response, err := d.sendDeviceDataResponse(ctx, loginData)
 	if err != nil {
 		return fmt.Errorf("login failed: %w", err)
 	}
 
-	if response.Status != 0 {
-		return fmt.Errorf("login failed")
+	if response.Status != statusOK {
+		return newStatusError("login", response.Status)
 	}
 	return nil
 }
@@ -299,39 +622,54 @@ func (d *Device) logout() {
 }
 
 // requestValues from given definition
-func (d *Device) requestValues(ctx context.Context, def InverterValuesDef) (map[ValueID]interface{}, error) {
+func (d *Device) requestValues(ctx context.Context, def InverterValuesDef) (map[ValueID]TimedValue, error) {
 	Log.Printf("requestValues for %s: 0x%X 0x%X 0x%X", d.address, def.Object, def.Start, def.End)
 	request := net2.NewDeviceData(0xa0)
 	request.Object = def.Object
 	request.AddParameter(def.Start)
 	request.AddParameter(def.End)
 
-	response, err := d.sendDeviceDataResponse(request, time.Millisecond*500, ctx)
+	response, err := d.sendDeviceDataResponse(ctx, request)
 	if err != nil {
 		return nil, err
 	}
 
-	if response.Status == 0x15 {
-		return nil, nil
-	}
-	if response.Status != 0 {
-		return nil, fmt.Errorf("failed to get values")
+	if response.Status != statusOK {
+		return nil, newStatusError("request values", response.Status)
 	}
 
 	return parseInverterValues(response.ResponseValues), nil
 }
 
+// writeValue with given definition
+func (d *Device) writeValue(ctx context.Context, def InverterValuesDef, value *net2.ResponseValue) error {
+	Log.Printf("writeValue for %s: 0x%X 0x%X", d.address, def.Start, value.Code)
+	request := net2.NewDeviceData(0xa0)
+	request.Command = 0x0a
+	request.Object = 0xf000
+	request.AddParameter(def.Start)
+	request.AddParameter(def.Start)
+	request.Data = value.Bytes(def.Object)
+
+	response, err := d.sendDeviceDataResponse(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	if response.Status != statusOK {
+		return newStatusError("write value", response.Status)
+	}
+	return nil
+}
+
 // sendDeviceDataResponse sends the package and wait for response
-func (d *Device) sendDeviceDataResponse(data *net2.DeviceData,
-	resendInterval time.Duration, ctx context.Context) (*net2.DeviceData, error) {
-	for {
-		// stop after timeout
-		select {
-		case <-ctx.Done():
-			return nil, fmt.Errorf("no packet received in timeout")
-		default:
-		}
+// The request is resent based on the policy of the device.
+func (d *Device) sendDeviceDataResponse(ctx context.Context, data *net2.DeviceData) (*net2.DeviceData, error) {
+	response := d.mux.register(data)
+	defer d.mux.unregister(data)
 
+	policy := d.policyFor(ctx)
+	for retry := 0; policy.MaxRetries <= 0 || retry <= policy.MaxRetries; retry++ {
 		// send request
 		err := d.sendDeviceData(data)
 		if err != nil {
@@ -339,29 +677,26 @@ func (d *Device) sendDeviceDataResponse(data *net2.DeviceData,
 		}
 
 		// wait for response
-		receiveCtx, cancel := context.WithTimeout(ctx, resendInterval)
-
-		// wait for package until timeout
-		for {
-			select {
-			case <-receiveCtx.Done():
-				cancel()
-				return nil, fmt.Errorf("no packet received in timeout")
-			default:
-			}
-
-			responseData, err := d.readNet2DeviceData(receiveCtx, data.PacketID)
-			if err != nil {
-				continue // no valid packet
+		timer := time.NewTimer(policy.resendInterval(retry))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if ctx.Err() == context.Canceled {
+				return nil, ctx.Err()
 			}
-			cancel()
+			return nil, fmt.Errorf("%w: no response from %s", ErrTimeout, d.address.IP)
+		case responseData := <-response:
+			timer.Stop()
 			return responseData, nil
+		case <-timer.C:
 		}
 	}
+	return nil, fmt.Errorf("%w: no response from %s after %d retries", ErrTimeout, d.address.IP, policy.MaxRetries)
 }
 
 // sendDeviceData sends the package
 func (d *Device) sendDeviceData(data *net2.DeviceData) error {
+	data.Source = d.conn.identity
 	if d.id.SusyID == 0 && d.id.SerialNumber == 0 {
 		data.Destination.SusyID = 0xFFFF
 		data.Destination.SerialNumber = 0xFFFFFFFF
@@ -379,50 +714,3 @@ func (d *Device) sendDeviceData(data *net2.DeviceData) error {
 
 	return d.conn.sendPacket(d.address, &pack)
 }
-
-// readNet2 read package from Connection
-func (d *Device) readNet2(ctx context.Context) (*proto.SmaNet2PacketEntry, error) {
-	var packet *proto.Packet
-	select {
-	case packet = <-d.receiver:
-	case <-ctx.Done():
-		return nil, fmt.Errorf("device does not respond at %s", d.address.IP.String())
-	}
-
-	entry := packet.GetEntry(proto.SmaNet2PacketEntryTag)
-	if entry == nil {
-		return nil, fmt.Errorf("received invalid response from %s", d.address.IP.String())
-	}
-
-	return entry.(*proto.SmaNet2PacketEntry), nil
-}
-
-// readNet2DeviceData read package from Connection
-func (d *Device) readNet2DeviceData(ctx context.Context, pkgId uint16) (*net2.DeviceData, error) {
-	entry, err := d.readNet2(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	responseData, ok := entry.Content.(*net2.DeviceData)
-	if !ok {
-		return nil, fmt.Errorf("invalid data received from %s", d.address.IP.String())
-	}
-
-	if responseData.PacketID != pkgId {
-		return nil, fmt.Errorf("invalid package received from %s (%d)", d.address.IP.String(), responseData.PacketID)
-	}
-
-	return responseData, nil
-}
-
-// clearReceiver channel packages
-func (d *Device) clearReceiver() {
-	for {
-		select {
-		case <-d.receiver:
-		default:
-			return
-		}
-	}
-}
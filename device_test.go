@@ -0,0 +1,530 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny/proto"
+	"gitlab.com/bboehmke/sunny/proto/net2"
+)
+
+// simulatedInverter answers speedwire requests on 127.0.0.1:9522
+type simulatedInverter struct {
+	id       net2.DeviceId
+	password string
+	socket   *net.UDPConn
+
+	sessions map[net2.DeviceId]bool
+	values   map[uint32]uint32
+
+	mutex sync.Mutex
+	// statuses returned for requests of an object
+	statuses map[uint16]uint16
+	// requests received per object
+	requests map[uint16]int
+}
+
+// newSimulatedInverter starts a simulated inverter or skips the test
+func newSimulatedInverter(t *testing.T) *simulatedInverter {
+	socket, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9522})
+	if err != nil {
+		t.Skipf("failed to listen on speedwire port: %v", err)
+	}
+
+	sim := &simulatedInverter{
+		id:       net2.DeviceId{SusyID: 0x1234, SerialNumber: 2000000001},
+		password: "0000",
+		socket:   socket,
+		sessions: make(map[net2.DeviceId]bool),
+		values:   make(map[uint32]uint32),
+		statuses: make(map[uint16]uint16),
+		requests: make(map[uint16]int),
+	}
+	go sim.run()
+	t.Cleanup(func() {
+		_ = socket.Close()
+	})
+	return sim
+}
+
+// setStatus returned for all requests of object
+func (s *simulatedInverter) setStatus(object, status uint16) {
+	s.mutex.Lock()
+	s.statuses[object] = status
+	s.mutex.Unlock()
+}
+
+// status for requests of object (0 if not set)
+func (s *simulatedInverter) status(object uint16) uint16 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.statuses[object]
+}
+
+// requestCount of object
+func (s *simulatedInverter) requestCount(object uint16) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests[object]
+}
+
+// run handles requests until the socket is closed
+func (s *simulatedInverter) run() {
+	buffer := make([]byte, 1024)
+	for {
+		n, address, err := s.socket.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+
+		var packet proto.Packet
+		if packet.Read(buffer[:n]) != nil {
+			continue
+		}
+		entry := packet.GetEntry(proto.SmaNet2PacketEntryTag)
+		if entry == nil {
+			continue
+		}
+		request, ok := entry.(*proto.SmaNet2PacketEntry).Content.(*net2.DeviceData)
+		if !ok {
+			continue
+		}
+		s.mutex.Lock()
+		s.requests[request.Object]++
+		s.mutex.Unlock()
+
+		// request data is not parsed -> extract from raw packet
+		// (header 18 bytes, end marker 4 bytes)
+		index := 18 + 28 + 4*len(request.Parameters)
+		if index < n-4 {
+			request.Data = append([]byte{}, buffer[index:n-4]...)
+		}
+
+		response := s.handle(request)
+		if response == nil {
+			continue
+		}
+
+		// respond in random order
+		go func(address *net.UDPAddr) {
+			time.Sleep(time.Duration(rand.Intn(3)) * time.Millisecond)
+
+			var pack proto.Packet
+			pack.AddEntry(&proto.GroupPacketEntry{Group: 0x00000001})
+			pack.AddEntry(&proto.SmaNet2PacketEntry{Content: response})
+			_, _ = s.socket.WriteToUDP(pack.Bytes(), address)
+		}(address)
+	}
+}
+
+// handle request and return response (nil if no response is sent)
+func (s *simulatedInverter) handle(request *net2.DeviceData) *net2.DeviceData {
+	response := &net2.DeviceData{
+		Control:     request.Control,
+		Destination: request.Source,
+		JobNumber:   request.JobNumber,
+		Source:      s.id,
+		PacketID:    request.PacketID,
+		Command:     request.Command | 0x01,
+		Object:      request.Object,
+		Parameters:  request.Parameters,
+	}
+
+	switch {
+	// logout
+	case request.Command == 0x0e:
+		delete(s.sessions, request.Source)
+		return nil
+
+	// login
+	case request.Command == 0x0c:
+		password := make([]byte, 0, len(request.Data))
+		for _, b := range request.Data {
+			if b != 0x88 {
+				password = append(password, b-0x88)
+			}
+		}
+		if string(password) != s.password {
+			response.Status = 0x0100
+			return response
+		}
+		s.sessions[request.Source] = true
+		return response
+
+	// ping
+	case request.Object == 0:
+		return response
+
+	// all other requests require a login
+	case !s.sessions[request.Source]:
+		response.Status = 0x0017
+		return response
+
+	case s.status(request.Object) != 0:
+		response.Status = s.status(request.Object)
+		return response
+
+	// write value
+	case request.Command == 0x0a:
+		var value net2.ResponseValue
+		_, err := value.Read(request.Data, request.Object)
+		if err != nil || len(value.Values) == 0 {
+			response.Status = 0x0015
+			return response
+		}
+		s.values[request.Parameters[0]], _ = value.Values[0].(uint32)
+		return response
+
+	// firmware version
+	case request.Object == 0x5800 && request.Parameters[0] == 0x00823400:
+		response.Data = (&net2.ResponseValue{
+			Code:      0x8234,
+			Type:      0x00,
+			Timestamp: uint32(time.Now().Unix()),
+			Values:    []interface{}{uint32(0), uint32(0x03101204)},
+		}).Bytes(request.Object)
+		return response
+
+	// device name, class and type
+	case request.Object == 0x5800:
+		timestamp := uint32(time.Now().Unix())
+		response.Data = (&net2.ResponseValue{
+			Code:      0x821E,
+			Type:      0x10,
+			Timestamp: timestamp,
+			Values:    []interface{}{"Simulated Inverter"},
+		}).Bytes(request.Object)
+		response.Data = append(response.Data, (&net2.ResponseValue{
+			Code:      0x821F,
+			Type:      0x08,
+			Timestamp: timestamp,
+			Values:    []interface{}{uint32(8001)},
+		}).Bytes(request.Object)...)
+		response.Data = append(response.Data, (&net2.ResponseValue{
+			Code:      0x8220,
+			Type:      0x08,
+			Timestamp: timestamp,
+			Values:    []interface{}{uint32(9404)},
+		}).Bytes(request.Object)...)
+		return response
+
+	// nominal power
+	case request.Object == 0x5100 && request.Parameters[0] == 0x00411E00:
+		response.Data = (&net2.ResponseValue{
+			Code:      0x411E,
+			Type:      0x00,
+			Timestamp: uint32(time.Now().Unix()),
+			Values:    []interface{}{uint32(5000)},
+		}).Bytes(request.Object)
+		return response
+
+	// power of phases (single phase inverter)
+	case request.Object == 0x5100 && request.Parameters[0] == 0x00464000:
+		timestamp := uint32(time.Now().Unix())
+		response.Data = (&net2.ResponseValue{
+			Code:      0x4640,
+			Type:      0x40,
+			Timestamp: timestamp,
+			Values:    []interface{}{int32(1500)},
+		}).Bytes(request.Object)
+		for _, code := range []uint16{0x4641, 0x4642} {
+			response.Data = append(response.Data, (&net2.ResponseValue{
+				Code:      code,
+				Type:      0x40,
+				Timestamp: timestamp,
+				Values:    []interface{}{int32(-0x80000000)}, // NaN
+			}).Bytes(request.Object)...)
+		}
+		return response
+
+	default:
+		response.Status = 0x0015
+		return response
+	}
+}
+
+func TestDevice_Concurrent(t *testing.T) {
+	ass := assert.New(t)
+	sim := newSimulatedInverter(t)
+
+	device, err := NewUnicastDevice("127.0.0.1", "0000")
+	if !ass.NoError(err) {
+		return
+	}
+	defer device.Close()
+	ass.Equal(sim.id, device.ID())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			value, err := device.GetValue(DeviceName)
+			ass.NoError(err)
+			ass.Equal("Simulated Inverter", value)
+		}()
+		go func() {
+			defer wg.Done()
+			values, err := device.GetValues()
+			ass.NoError(err)
+			ass.Equal("Simulated Inverter", values[DeviceName])
+		}()
+		go func() {
+			defer wg.Done()
+			device.SetPassword("0000")
+		}()
+	}
+	wg.Wait()
+}
+
+func TestDevice_SetPassword(t *testing.T) {
+	ass := assert.New(t)
+	newSimulatedInverter(t)
+
+	device, err := NewUnicastDevice("127.0.0.1", "1111")
+	if !ass.NoError(err) {
+		return
+	}
+	defer device.Close()
+
+	value, err := device.GetValue(DeviceName)
+	ass.True(errors.Is(err, ErrAuth))
+	ass.Nil(value)
+
+	var statusErr *StatusError
+	if ass.True(errors.As(err, &statusErr)) {
+		ass.Equal(uint16(0x0100), statusErr.Status)
+	}
+
+	device.SetPassword("0000")
+	value, err = device.GetValue(DeviceName)
+	ass.NoError(err)
+	ass.Equal("Simulated Inverter", value)
+}
+
+func TestDevice_Errors(t *testing.T) {
+	ass := assert.New(t)
+	sim := newSimulatedInverter(t)
+
+	device, err := NewUnicastDevice("127.0.0.1", "0000")
+	if !ass.NoError(err) {
+		return
+	}
+	defer device.Close()
+
+	value, err := device.GetValue(DeviceTemperature)
+	ass.True(errors.Is(err, ErrNotSupported))
+	ass.Nil(value)
+
+	err = device.SetValue(DeviceName, "test")
+	ass.True(errors.Is(err, ErrNotSupported))
+
+	// device does not respond anymore
+	_ = sim.socket.Close()
+	ctx := WithPolicy(context.Background(), Policy{
+		Timeout:        time.Millisecond * 200,
+		ResendInterval: time.Millisecond * 50,
+		LoginRetries:   1,
+	})
+	value, err = device.GetValueCtx(ctx, DeviceName)
+	ass.True(errors.Is(err, ErrTimeout))
+	ass.Nil(value)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = device.GetValueCtx(canceled, DeviceName)
+	ass.True(errors.Is(err, context.Canceled))
+}
+
+func TestDevice_GetValuesResult(t *testing.T) {
+	ass := assert.New(t)
+	sim := newSimulatedInverter(t)
+	sim.setStatus(0x5200, 0x0002)
+
+	device, err := NewUnicastDevice("127.0.0.1", "0000")
+	if !ass.NoError(err) {
+		return
+	}
+	defer device.Close()
+
+	result, err := device.GetValuesResult()
+	if !ass.NoError(err) {
+		return
+	}
+	ass.False(result.Complete())
+	ass.Equal("Simulated Inverter", result.Values[DeviceName].Value)
+
+	for _, def := range result.Succeeded {
+		ass.Contains([]uint16{0x5100, 0x5800}, def.Object)
+	}
+	for _, def := range result.Unsupported {
+		ass.NotContains([]uint16{0x5200, 0x5800}, def.Object)
+	}
+	if ass.Len(result.Failed, 1) {
+		ass.Equal(uint16(0x5200), result.Failed[0].Def.Object)
+		ass.True(errors.Is(result.Failed[0], ErrDeviceBusy))
+	}
+	ass.Len(getAllInverterRequests(),
+		len(result.Succeeded)+len(result.Unsupported)+len(result.Failed))
+	ass.True(errors.Is(result.Err(), ErrDeviceBusy))
+
+	// partial values without error
+	values, err := device.GetValues()
+	ass.NoError(err)
+	ass.Equal("Simulated Inverter", values[DeviceName])
+}
+
+func TestDevice_SupportedValues(t *testing.T) {
+	ass := assert.New(t)
+	sim := newSimulatedInverter(t)
+
+	device, err := NewUnicastDevice("127.0.0.1", "0000")
+	if !ass.NoError(err) {
+		return
+	}
+	defer device.Close()
+
+	ids, err := device.SupportedValues()
+	ass.NoError(err)
+	ass.Contains(ids, DeviceName)
+	ass.Contains(ids, DeviceClass)
+	ass.NotContains(ids, DeviceStatus)
+	probed := sim.requestCount(0x5180)
+	ass.True(probed > 0)
+
+	// only returned phases are supported
+	ass.Contains(ids, ActivePowerPlusL1)
+	ass.NotContains(ids, ActivePowerPlusL2)
+	ass.NotContains(ids, ActivePowerPlusL3)
+
+	// unsupported requests are skipped
+	values, err := device.GetValues()
+	ass.NoError(err)
+	ass.Equal("Simulated Inverter", values[DeviceName])
+	ass.Equal(probed, sim.requestCount(0x5180))
+
+	_, err = device.GetValue(DeviceStatus)
+	ass.True(errors.Is(err, ErrNotSupported))
+	ass.Equal(probed, sim.requestCount(0x5180))
+
+	// probe again after cache reset
+	ass.NoError(device.capabilityCache().Forget(sim.id.SerialNumber))
+	for i := 0; i < unsupportedStrikes+1; i++ {
+		_, err = device.GetValues()
+		ass.NoError(err)
+	}
+	ass.Equal(2*probed, sim.requestCount(0x5180))
+}
+
+func TestDevice_Info(t *testing.T) {
+	ass := assert.New(t)
+	sim := newSimulatedInverter(t)
+
+	device, err := NewUnicastDevice("127.0.0.1", "0000")
+	if !ass.NoError(err) {
+		return
+	}
+	defer device.Close()
+
+	info, err := device.Info(context.Background())
+	ass.NoError(err)
+	ass.Equal(DeviceInfo{
+		SerialNumber: sim.id.SerialNumber,
+		SusyID:       sim.id.SusyID,
+		Name:         "Simulated Inverter",
+		Class:        8001,
+		ClassName:    "Solar Inverters",
+		Type:         9404,
+		Model:        "SB 5.0-1AV-41",
+		Product: Product{
+			Model:       "SB 5.0-1AV-41",
+			Family:      FamilySunnyBoy,
+			Phases:      1,
+			MPPTrackers: 2,
+		},
+		Firmware:     Version{Major: 3, Minor: 10, Build: 18, Release: 'R'},
+		NominalPower: 5000,
+	}, info)
+
+	ass.NoError(device.requireFirmware(context.Background(), Version{Major: 3, Minor: 10}))
+	err = device.requireFirmware(context.Background(), Version{Major: 3, Minor: 11})
+	ass.True(errors.Is(err, ErrNotSupported))
+
+	// cached
+	requests := sim.requestCount(0x5800)
+	info, err = device.Info(context.Background())
+	ass.NoError(err)
+	ass.Equal("Simulated Inverter", info.Name)
+	ass.Equal(requests, sim.requestCount(0x5800))
+}
+
+func TestDevice_SetValueFirmware(t *testing.T) {
+	ass := assert.New(t)
+	newSimulatedInverter(t)
+
+	device, err := NewUnicastDevice("127.0.0.1", "0000")
+	if !ass.NoError(err) {
+		return
+	}
+	defer device.Close()
+
+	// parameter requires newer firmware than 3.10.18.R
+	device.parameters = map[ValueID]Version{
+		ActivePowerLimit: {Major: 3, Minor: 11},
+	}
+	err = device.SetValue(ActivePowerLimit, 3000.0)
+	ass.True(errors.Is(err, ErrNotSupported))
+	ass.Contains(err.Error(), "firmware 3.10.18.R is older than 3.11")
+
+	device.parameters = map[ValueID]Version{
+		ActivePowerLimit: {Major: 3, Minor: 10},
+	}
+	ass.NoError(device.SetValue(ActivePowerLimit, 3000.0))
+}
+
+func TestDevice_EnergyMeterContext(t *testing.T) {
+	ass := assert.New(t)
+
+	// no broadcasts are received
+	conn := &Connection{
+		policy:           DefaultPolicy,
+		receiverChannels: make(map[string][]chan *proto.Packet),
+	}
+	device, err := conn.newDiscoveredDevice(meterResult("10.0.0.1", 3000000001), "")
+	if !ass.NoError(err) {
+		return
+	}
+	defer device.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*20, cancel)
+	_, err = device.GetValuesResultCtx(ctx)
+	ass.True(errors.Is(err, context.Canceled))
+	ass.False(errors.Is(err, ErrTimeout))
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	_, err = device.GetValuesResultCtx(ctx)
+	ass.True(errors.Is(err, ErrTimeout))
+}
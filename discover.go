@@ -16,10 +16,15 @@ package sunny
 
 import (
 	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
 	"sync"
 	"time"
 
 	"gitlab.com/bboehmke/sunny/proto"
+	"gitlab.com/bboehmke/sunny/proto/net2"
 )
 
 // SimpleDiscoverDevices in Connection with a simpler interface
@@ -50,14 +55,15 @@ func (c *Connection) SimpleDiscoverDevices(password string) []*Device {
 // DiscoverDevices in Connection
 func (c *Connection) DiscoverDevices(ctx context.Context, devices chan *Device, password string) {
 	var wg sync.WaitGroup
-	knownIps := make(map[string]*Device)
+	knownIps := make(map[string]bool)
 	var knownMutex sync.Mutex
 	ticker := time.NewTicker(time.Millisecond * 500)
 
-	discoverCh := make(chan string)
+	discoverCh := make(chan discoveryResult, 10)
 	c.registerDiscoverer(discoverCh)
 	defer c.unregisterDiscoverer(discoverCh)
 
+	c.sendDiscoveryRequest()
 loop:
 	for {
 		select {
@@ -65,36 +71,287 @@ loop:
 			break loop
 
 		// handle received responses
-		case ip := <-discoverCh:
+		case result := <-discoverCh:
+			knownMutex.Lock()
+			known := knownIps[result.ip]
+			knownIps[result.ip] = true
+			knownMutex.Unlock()
+			if known {
+				continue // already found or in progress
+			}
+
 			wg.Add(1)
-			go func(ip string) {
-				knownMutex.Lock()
-				defer knownMutex.Unlock()
-
-				if _, ok := knownIps[ip]; !ok {
-					device, err := c.NewDevice(ip, password)
-					if err != nil {
-						Log.Printf("discover - skip ip %s: %v", ip, err)
-					} else {
-						Log.Printf("found device %d at %s", device.SerialNumber(), ip)
-						knownIps[ip] = device
-						devices <- device
-					}
-				}
+			go func(result discoveryResult) {
+				defer wg.Done()
+
+				device, err := c.newDiscoveredDevice(result, password)
+				if err != nil {
+					Log.Printf("discover - skip ip %s: %v", result.ip, err)
 
-				wg.Done()
-			}(ip)
+					// allow retry with next response
+					knownMutex.Lock()
+					delete(knownIps, result.ip)
+					knownMutex.Unlock()
+					return
+				}
+				Log.Printf("found device %d at %s", device.SerialNumber(), result.ip)
+				devices <- device
+			}(result)
 
 		// send discover packages
 		case <-ticker.C:
-			// send discover packet
-			Log.Printf("send discover package")
-			_, err := c.socket.WriteTo(proto.NewDiscoveryRequest().Bytes(), c.address)
+			c.sendDiscoveryRequest()
+		}
+	}
+	ticker.Stop()
+	wg.Wait()
+}
+
+// maxSweepHosts limits the amount of hosts of a single sweep
+const maxSweepHosts = 65536
+
+// maxSweepRate limits the requests per second of a sweep
+const maxSweepRate = 10000
+
+// SweepDiscoverDevices sends unicast ping and discovery requests to all hosts
+// of the given targets (CIDR ranges like "192.168.1.0/24" or single hosts)
+// with at most rate requests per second (0 for the default of 50).
+// Every responding inverter is created in unicast mode (see NewUnicastDevice)
+// and sent to devices. Energy meters of the targets are detected by their
+// broadcasts if the multicast connection of the default interface can be
+// opened.
+// This is useful in networks where multicast discovery is filtered.
+// The function returns when the context is done (hosts that were not
+// requested until then are skipped) and all found devices are sent.
+func SweepDiscoverDevices(ctx context.Context, targets []string, rate int, devices chan *Device, password string) error {
+	return SweepDiscoverDevicesWithIdentity(ctx, targets, rate, devices, password, *net2.LocalDeviceId())
+}
+
+// SweepDiscoverDevicesWithIdentity works like SweepDiscoverDevices but the
+// requests of the sweep and of the found devices use the given identity
+// (see NewConnectionWithIdentity)
+func SweepDiscoverDevicesWithIdentity(ctx context.Context, targets []string, rate int,
+	devices chan *Device, password string, identity net2.DeviceId) error {
+	if rate == 0 {
+		rate = 50
+	}
+	if rate < 0 || rate > maxSweepRate {
+		return fmt.Errorf("invalid sweep rate %d (1-%d requests per second)", rate, maxSweepRate)
+	}
+	hosts, err := sweepHosts(targets)
+	if err != nil {
+		return err
+	}
+
+	conn, err := newUnicastConnection(identity)
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+
+	discoverCh := make(chan discoveryResult, 10)
+	conn.registerDiscoverer(discoverCh)
+	defer conn.unregisterDiscoverer(discoverCh)
+
+	// energy meters do not respond to unicast requests -> use broadcasts
+	meterConn, err := NewConnectionWithIdentity("", identity)
+	if err != nil {
+		Log.Printf("sweep - energy meters are not detected: %v", err)
+	} else {
+		meterConn.registerDiscoverer(discoverCh)
+		defer meterConn.unregisterDiscoverer(discoverCh)
+	}
+	targetIps := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		targetIps[host.String()] = true
+	}
+
+	// send requests to all hosts with limited rate
+	sendCtx, sendCancel := context.WithCancel(ctx)
+	sendDone := make(chan struct{})
+	defer func() {
+		sendCancel()
+		<-sendDone
+	}()
+	go func() {
+		defer close(sendDone)
+		ticker := time.NewTicker(time.Second / time.Duration(rate))
+		defer ticker.Stop()
+
+		for _, host := range hosts {
+			select {
+			case <-sendCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			conn.sendSweepRequests(host)
+		}
+	}()
+
+	// discovery responses contain no ID -> ping device if no ping
+	// response is received within the resend interval
+	done := make(chan struct{})
+	defer close(done)
+	fallback := make(chan string, 10)
+	pending := make(map[string]bool)
+	grace := conn.Policy().ResendInterval
+
+	var wg sync.WaitGroup
+	knownIps := make(map[string]bool)
+	found := func(newDevice func() (*Device, error), ip string) {
+		knownIps[ip] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			device, err := newDevice()
 			if err != nil {
-				Log.Printf("failed to send packet: %w", err)
+				Log.Printf("sweep - skip ip %s: %v", ip, err)
+				return
 			}
+			Log.Printf("found device %d (SusyID %d) at %s",
+				device.id.SerialNumber, device.id.SusyID, ip)
+			devices <- device
+		}()
+	}
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+
+		case result := <-discoverCh:
+			ip := result.ip
+			if knownIps[ip] || !targetIps[ip] {
+				continue
+			}
+
+			switch {
+			case result.energyMeter:
+				if meterConn == nil {
+					continue
+				}
+				found(func() (*Device, error) {
+					return meterConn.newDiscoveredDevice(result, password)
+				}, ip)
+
+			case result.id.SerialNumber != 0:
+				found(func() (*Device, error) {
+					return newUnicastDiscoveredDevice(result, password, identity)
+				}, ip)
+
+			case !pending[ip]:
+				pending[ip] = true
+				time.AfterFunc(grace, func() {
+					select {
+					case fallback <- ip:
+					case <-done:
+					}
+				})
+			}
+
+		case ip := <-fallback:
+			if knownIps[ip] {
+				continue
+			}
+			found(func() (*Device, error) {
+				return NewUnicastDeviceWithIdentity(ctx, ip, password, identity)
+			}, ip)
 		}
 	}
-	ticker.Stop()
 	wg.Wait()
+	return nil
+}
+
+// newUnicastDiscoveredDevice creates a device in unicast mode from the ping
+// response of an inverter (no further ping required)
+func newUnicastDiscoveredDevice(result discoveryResult, password string, identity net2.DeviceId) (*Device, error) {
+	conn, err := newUnicastConnection(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	device, err := conn.newDevice(result.ip, password)
+	if err != nil {
+		conn.close()
+		return nil, err
+	}
+	Log.Printf("new inverter at %s - Serial=%d", result.ip, result.id.SerialNumber)
+	device.id = result.id
+	return device, nil
+}
+
+// sendSweepRequests sends a ping and a discovery request to host
+func (c *Connection) sendSweepRequests(host net.IP) {
+	address := &net.UDPAddr{IP: host, Port: 9522}
+
+	err := c.write(proto.NewDiscoveryRequest().Bytes(), address)
+	if err != nil {
+		Log.Printf("sweep - failed to send discovery request to %s: %v", host, err)
+	}
+
+	pingData := net2.NewDeviceData(0xa0)
+	pingData.AddParameter(0)
+	pingData.AddParameter(0)
+	pingData.Source = c.identity
+	pingData.Destination.SusyID = 0xFFFF
+	pingData.Destination.SerialNumber = 0xFFFFFFFF
+
+	var pack proto.Packet
+	pack.AddEntry(&proto.GroupPacketEntry{
+		Group: 0x00000001,
+	})
+	pack.AddEntry(&proto.SmaNet2PacketEntry{
+		Content: pingData,
+	})
+	err = c.sendPacket(address, &pack)
+	if err != nil {
+		Log.Printf("sweep - failed to send ping to %s: %v", host, err)
+	}
+}
+
+// sweepHosts returns all host addresses of the given targets
+func sweepHosts(targets []string) ([]net.IP, error) {
+	var hosts []net.IP
+	for _, target := range targets {
+		target = strings.TrimSpace(target)
+		if target == "" {
+			continue
+		}
+
+		if !strings.Contains(target, "/") {
+			ip, err := net.ResolveIPAddr("ip4", target)
+			if err != nil {
+				return nil, fmt.Errorf("invalid sweep target %s: %w", target, err)
+			}
+			hosts = append(hosts, ip.IP)
+			continue
+		}
+
+		ip, network, err := net.ParseCIDR(target)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sweep target %s: %w", target, err)
+		}
+		if ip.To4() == nil {
+			return nil, fmt.Errorf("invalid sweep target %s: only IPv4 is supported", target)
+		}
+
+		ones, bits := network.Mask.Size()
+		size := 1 << uint(bits-ones)
+		if len(hosts)+size > maxSweepHosts {
+			return nil, fmt.Errorf("sweep targets contain more than %d hosts", maxSweepHosts)
+		}
+
+		base := binary.BigEndian.Uint32(network.IP.To4())
+		for i := 0; i < size; i++ {
+			// skip network and broadcast address
+			if size > 2 && (i == 0 || i == size-1) {
+				continue
+			}
+			host := make(net.IP, 4)
+			binary.BigEndian.PutUint32(host, base+uint32(i))
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, nil
 }
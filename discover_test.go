@@ -0,0 +1,159 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny/proto/net2"
+)
+
+func TestSweepHosts(t *testing.T) {
+	ass := assert.New(t)
+
+	tests := []struct {
+		targets []string
+		hosts   []string
+	}{
+		{nil, nil},
+		{[]string{"", " "}, nil},
+		{[]string{"10.0.0.5"}, []string{"10.0.0.5"}},
+		{[]string{" 10.0.0.5 ", "10.0.1.7"}, []string{"10.0.0.5", "10.0.1.7"}},
+		// network and broadcast address are skipped
+		{[]string{"192.168.1.0/30"}, []string{"192.168.1.1", "192.168.1.2"}},
+		{[]string{"192.168.1.9/30"}, []string{"192.168.1.9", "192.168.1.10"}},
+		// point to point and single host networks
+		{[]string{"192.168.1.4/31"}, []string{"192.168.1.4", "192.168.1.5"}},
+		{[]string{"192.168.1.4/32"}, []string{"192.168.1.4"}},
+		{[]string{"10.0.0.1", "192.168.1.0/30"}, []string{"10.0.0.1", "192.168.1.1", "192.168.1.2"}},
+	}
+	for _, test := range tests {
+		hosts, err := sweepHosts(test.targets)
+		ass.NoError(err, "%v", test.targets)
+
+		var ips []string
+		for _, host := range hosts {
+			ips = append(ips, host.String())
+		}
+		ass.Equal(test.hosts, ips, "%v", test.targets)
+	}
+
+	hosts, err := sweepHosts([]string{"10.0.0.0/24"})
+	ass.NoError(err)
+	ass.Len(hosts, 254)
+	hosts, err = sweepHosts([]string{"10.0.0.0/16"})
+	ass.NoError(err)
+	ass.Len(hosts, 65534)
+
+	for _, targets := range [][]string{
+		{"10.0.0.0/33"},
+		{"10.0.0.0/xx"},
+		{"fd00::/120"},
+		{"10.0.0.0/15"},
+		{"10.0.0.0/16", "10.1.0.0/24"},
+	} {
+		_, err := sweepHosts(targets)
+		ass.Error(err, "%v", targets)
+	}
+}
+
+func TestSweepDiscoverDevices_Rate(t *testing.T) {
+	ass := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	devices := make(chan *Device, 1)
+	for _, rate := range []int{-1, maxSweepRate + 1, 2000000000} {
+		err := SweepDiscoverDevices(ctx, []string{"127.0.0.1"}, rate, devices, "0000")
+		ass.Error(err, "rate %d", rate)
+	}
+}
+
+func TestSweepDiscoverDevices_Canceled(t *testing.T) {
+	ass := assert.New(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+
+	// returns on cancel even if not all hosts are requested
+	start := time.Now()
+	devices := make(chan *Device, 1)
+	ass.NoError(SweepDiscoverDevices(ctx, []string{"127.0.0.0/24"}, 1, devices, "0000"))
+	ass.True(time.Since(start) < time.Second)
+	ass.Empty(devices)
+}
+
+func TestSweepDiscoverDevices(t *testing.T) {
+	ass := assert.New(t)
+	sim := newSimulatedInverter(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*300)
+	defer cancel()
+
+	devices := make(chan *Device, 10)
+	ass.NoError(SweepDiscoverDevices(ctx, []string{"127.0.0.1"}, 100, devices, "0000"))
+	close(devices)
+
+	var found []*Device
+	for device := range devices {
+		found = append(found, device)
+	}
+	if !ass.Len(found, 1) {
+		return
+	}
+	device := found[0]
+	defer device.Close()
+
+	// device is created from the ping response of the sweep
+	ass.Equal(sim.id, device.ID())
+	ass.False(device.IsEnergyMeter())
+	ass.True(device.Address().IP.Equal(net.IPv4(127, 0, 0, 1)))
+	ass.Equal(1, sim.requestCount(0))
+
+	name, err := device.GetValue(DeviceName)
+	ass.NoError(err)
+	ass.Equal("Simulated Inverter", name)
+}
+
+func TestSweepDiscoverDevicesWithIdentity(t *testing.T) {
+	ass := assert.New(t)
+	sim := newSimulatedInverter(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*300)
+	defer cancel()
+
+	identity := net2.DeviceId{SusyID: identitySusyID, SerialNumber: 1234567890}
+	devices := make(chan *Device, 10)
+	ass.NoError(SweepDiscoverDevicesWithIdentity(ctx, []string{"127.0.0.1"}, 100, devices, "0000", identity))
+	close(devices)
+
+	device := <-devices
+	if !ass.NotNil(device) {
+		return
+	}
+	defer device.Close()
+	ass.Equal(sim.id, device.ID())
+	ass.Equal(identity, device.conn.Identity())
+
+	name, err := device.GetValue(DeviceName)
+	ass.NoError(err)
+	ass.Equal("Simulated Inverter", name)
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors of device operations (use errors.Is to check)
+var (
+	// ErrTimeout device did not respond in time
+	ErrTimeout = errors.New("sunny: device does not respond")
+	// ErrAuth login rejected (e.g. wrong password) or no valid session
+	ErrAuth = errors.New("sunny: authentication failed")
+	// ErrNotSupported value or operation is not supported by the device
+	ErrNotSupported = errors.New("sunny: not supported by device")
+	// ErrDeviceBusy device can not handle the request at the moment
+	ErrDeviceBusy = errors.New("sunny: device busy")
+	// ErrInvalidResponse device responded with an unexpected status
+	ErrInvalidResponse = errors.New("sunny: invalid response")
+)
+
+// status codes of device responses
+const (
+	statusOK           uint16 = 0x0000
+	statusBusy         uint16 = 0x0002
+	statusNotSupported uint16 = 0x0015
+	statusNotLoggedIn  uint16 = 0x0017
+)
+
+// StatusError of a device response with a status other than OK
+// It matches (errors.Is) ErrNotSupported, ErrAuth, ErrDeviceBusy or
+// ErrInvalidResponse depending on the status.
+type StatusError struct {
+	// Op that failed (e.g. "login")
+	Op string
+	// Status of the device response
+	Status uint16
+}
+
+// newStatusError for operation with status of response
+func newStatusError(op string, status uint16) error {
+	return &StatusError{
+		Op:     op,
+		Status: status,
+	}
+}
+
+// Error returns the error message
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s failed: %v (status 0x%04X)", e.Op, e.Unwrap(), e.Status)
+}
+
+// Unwrap returns the error category of the status
+func (e *StatusError) Unwrap() error {
+	switch {
+	case e.Status == statusNotSupported:
+		return ErrNotSupported
+	case e.Status == statusNotLoggedIn || e.Op == "login":
+		return ErrAuth
+	case e.Status == statusBusy:
+		return ErrDeviceBusy
+	default:
+		return ErrInvalidResponse
+	}
+}
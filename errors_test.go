@@ -0,0 +1,42 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusError(t *testing.T) {
+	ass := assert.New(t)
+
+	ass.True(errors.Is(newStatusError("request values", 0x0015), ErrNotSupported))
+	ass.True(errors.Is(newStatusError("request values", 0x0017), ErrAuth))
+	ass.True(errors.Is(newStatusError("login", 0x0100), ErrAuth))
+	ass.True(errors.Is(newStatusError("write value", 0x0002), ErrDeviceBusy))
+	ass.True(errors.Is(newStatusError("write value", 0x1234), ErrInvalidResponse))
+	ass.False(errors.Is(newStatusError("write value", 0x1234), ErrAuth))
+
+	err := fmt.Errorf("wrapped: %w", newStatusError("write value", 0x1234))
+	var statusErr *StatusError
+	if ass.True(errors.As(err, &statusErr)) {
+		ass.Equal("write value", statusErr.Op)
+		ass.Equal(uint16(0x1234), statusErr.Status)
+	}
+	ass.Equal("write value failed: sunny: invalid response (status 0x1234)", statusErr.Error())
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// CSVWriter writes measurements as CSV with one row per measurement
+// The columns are time, serial and all values ordered by ValueID.
+type CSVWriter struct {
+	writer *csv.Writer
+	header bool
+}
+
+// NewCSVWriter creates a new CSV writer
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{
+		writer: csv.NewWriter(w),
+	}
+}
+
+// Write measurement as row (header is written before first row)
+func (c *CSVWriter) Write(m Measurement) error {
+	ids := sunny.ValueIDValues()
+
+	if !c.header {
+		header := make([]string, 0, len(ids)+2)
+		header = append(header, "time", "serial")
+		for _, id := range ids {
+			header = append(header, id.String())
+		}
+		err := c.writer.Write(header)
+		if err != nil {
+			return err
+		}
+		c.header = true
+	}
+
+	var t time.Time
+	row := make([]string, 0, len(ids)+2)
+	row = append(row, "", strconv.FormatUint(uint64(m.Serial), 10))
+	for _, id := range ids {
+		value, ok := m.Values[id]
+		if !ok || value.Value == nil {
+			row = append(row, "")
+			continue
+		}
+		if value.Time.After(t) {
+			t = value.Time
+		}
+
+		if v, ok := value.Value.(float64); ok {
+			row = append(row, strconv.FormatFloat(v, 'f', -1, 64))
+		} else {
+			row = append(row, fmt.Sprint(value.Value))
+		}
+	}
+	if !t.IsZero() {
+		row[0] = t.UTC().Format(time.RFC3339)
+	}
+
+	return c.writer.Write(row)
+}
+
+// Flush buffered rows to the underlying writer
+func (c *CSVWriter) Flush() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
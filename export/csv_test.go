@@ -0,0 +1,66 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+func TestCSVWriter(t *testing.T) {
+	ass := assert.New(t)
+
+	var buffer bytes.Buffer
+	writer := NewCSVWriter(&buffer)
+	ass.NoError(writer.Write(testMeasurement()))
+	ass.NoError(writer.Write(NewMeasurement(42, map[sunny.ValueID]interface{}{
+		sunny.ActivePowerMax: 5000.0,
+	}, time.Time{})))
+	ass.NoError(writer.Flush())
+
+	records, err := csv.NewReader(&buffer).ReadAll()
+	ass.NoError(err)
+	ass.Len(records, 3)
+
+	ids := sunny.ValueIDValues()
+	ass.Len(records[0], len(ids)+2)
+	ass.Equal([]string{"time", "serial", ids[0].String(), ids[1].String()}, records[0][:4])
+
+	column := func(id sunny.ValueID) int {
+		for i, name := range records[0] {
+			if name == id.String() {
+				return i
+			}
+		}
+		return -1
+	}
+
+	ass.Equal("2020-09-13T12:26:50Z", records[1][0])
+	ass.Equal("123456", records[1][1])
+	ass.Equal("1234.5", records[1][column(sunny.ActivePowerPlus)])
+	ass.Equal("800", records[1][column(sunny.PowerS1)])
+	ass.Equal(`SN: "1 2"`, records[1][column(sunny.DeviceName)])
+	ass.Equal("", records[1][column(sunny.ActivePowerMax)])
+
+	ass.Equal("", records[2][0])
+	ass.Equal("42", records[2][1])
+	ass.Equal("5000", records[2][column(sunny.ActivePowerMax)])
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// measurement name for values without type
+const defaultMeasurement = "device"
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+	stringEscaper      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// influxLine with all fields of one measurement, phase and string
+type influxLine struct {
+	measurement string
+	phase       string
+	str         string
+
+	fields []string
+	time   time.Time
+}
+
+// WriteInflux writes the measurement in InfluxDB line protocol
+// Every value type (power, energy, ...) is a separate measurement with the
+// tags serial, phase and string. The newest device timestamp of all fields
+// in a line is used as timestamp.
+func WriteInflux(w io.Writer, m Measurement) error {
+	ids := make([]sunny.ValueID, 0, len(m.Values))
+	for id := range m.Values {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	lines := make(map[string]*influxLine)
+	var keys []string
+	for _, id := range ids {
+		value := m.Values[id]
+		field, ok := formatField(value.Value)
+		if !ok {
+			continue
+		}
+
+		measurement := sunny.GetValueInfo(id).Type
+		if measurement == "" {
+			measurement = defaultMeasurement
+		}
+		name, phase, str := SplitChannel(id)
+
+		key := measurement + "|" + phase + "|" + str
+		line, ok := lines[key]
+		if !ok {
+			line = &influxLine{
+				measurement: measurement,
+				phase:       phase,
+				str:         str,
+			}
+			lines[key] = line
+			keys = append(keys, key)
+		}
+
+		line.fields = append(line.fields, tagEscaper.Replace(name)+"="+field)
+		if value.Time.After(line.time) {
+			line.time = value.Time
+		}
+	}
+	sort.Strings(keys)
+
+	serial := strconv.FormatUint(uint64(m.Serial), 10)
+	for _, key := range keys {
+		line := lines[key]
+
+		var b strings.Builder
+		b.WriteString(measurementEscaper.Replace(line.measurement))
+		b.WriteString(",serial=")
+		b.WriteString(serial)
+		if line.phase != "" {
+			b.WriteString(",phase=")
+			b.WriteString(tagEscaper.Replace(line.phase))
+		}
+		if line.str != "" {
+			b.WriteString(",string=")
+			b.WriteString(tagEscaper.Replace(line.str))
+		}
+		b.WriteByte(' ')
+		b.WriteString(strings.Join(line.fields, ","))
+		if !line.time.IsZero() {
+			b.WriteByte(' ')
+			b.WriteString(strconv.FormatInt(line.time.UnixNano(), 10))
+		}
+		b.WriteByte('\n')
+
+		_, err := io.WriteString(w, b.String())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatField value in line protocol format
+func formatField(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case uint64:
+		// signed integer field -> clamp to keep the field type of existing series
+		if v > math.MaxInt64 {
+			v = math.MaxInt64
+		}
+		return strconv.FormatUint(v, 10) + "i", true
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10) + "i", true
+	case int64:
+		return strconv.FormatInt(v, 10) + "i", true
+	case int32:
+		return strconv.FormatInt(int64(v), 10) + "i", true
+	case int:
+		return strconv.Itoa(v) + "i", true
+	case string:
+		return `"` + stringEscaper.Replace(v) + `"`, true
+	default:
+		return "", false
+	}
+}
+
+// InfluxClient pushes measurements to an InfluxDB compatible write endpoint
+type InfluxClient struct {
+	// URL of the write endpoint including query parameters
+	// (e.g. http://localhost:8086/write?db=sunny or
+	// http://localhost:8086/api/v2/write?org=home&bucket=sunny)
+	URL string
+	// Token for authentication (optional)
+	Token string
+
+	// Client used for requests (http.DefaultClient if nil)
+	Client *http.Client
+}
+
+// Write measurements to the endpoint
+func (c *InfluxClient) Write(ctx context.Context, measurements ...Measurement) error {
+	var body bytes.Buffer
+	for _, m := range measurements {
+		err := WriteInflux(&body, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, &body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if c.Token != "" {
+		request.Header.Set("Authorization", "Token "+c.Token)
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to write measurements: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("failed to write measurements: %s %s",
+			response.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+func testMeasurement() Measurement {
+	t1 := time.Unix(1600000000, 0)
+	t2 := time.Unix(1600000010, 0)
+	return Measurement{
+		Serial: 123456,
+		Values: map[sunny.ValueID]sunny.TimedValue{
+			sunny.ActivePowerPlus:   {Value: 1234.5, Time: t1},
+			sunny.ActivePowerPlusL1: {Value: 400.0, Time: t1},
+			sunny.VoltageL1:         {Value: 230.1, Time: t2},
+			sunny.PowerS1:           {Value: uint32(800), Time: t1},
+			sunny.DeviceName:        {Value: `SN: "1 2"`, Time: t2},
+			sunny.ActiveEnergyPlus:  {Value: uint64(3600000), Time: t2},
+		},
+	}
+}
+
+func TestWriteInflux(t *testing.T) {
+	ass := assert.New(t)
+
+	var buffer bytes.Buffer
+	ass.NoError(WriteInflux(&buffer, testMeasurement()))
+	ass.Equal(`device,serial=123456 DeviceName="SN: \"1 2\"" 1600000010000000000
+energy,serial=123456 ActiveEnergyPlus=3600000i 1600000010000000000
+power,serial=123456,phase=L1 ActivePowerPlus=400 1600000000000000000
+power,serial=123456 ActivePowerPlus=1234.5 1600000000000000000
+power,serial=123456,string=1 Power=800i 1600000000000000000
+voltage,serial=123456,phase=L1 Voltage=230.1 1600000010000000000
+`, buffer.String())
+}
+
+func TestWriteInflux_WithoutTime(t *testing.T) {
+	ass := assert.New(t)
+
+	var buffer bytes.Buffer
+	ass.NoError(WriteInflux(&buffer, NewMeasurement(1, map[sunny.ValueID]interface{}{
+		sunny.UtilityFrequency: 50.01,
+		sunny.BatteryCharge:    nil,
+	}, time.Time{})))
+	ass.Equal("device,serial=1 UtilityFrequency=50.01\n", buffer.String())
+}
+
+func TestFormatField(t *testing.T) {
+	ass := assert.New(t)
+
+	tests := []struct {
+		value interface{}
+		field string
+	}{
+		{uint64(3600000), "3600000i"},
+		{uint64(math.MaxInt64), "9223372036854775807i"},
+		{uint64(math.MaxUint64), "9223372036854775807i"},
+		{uint32(math.MaxUint32), "4294967295i"},
+		{int64(-5), "-5i"},
+		{int32(-5), "-5i"},
+		{-5, "-5i"},
+		{0.5, "0.5"},
+		{`a "b"`, `"a \"b\""`},
+	}
+	for _, test := range tests {
+		field, ok := formatField(test.value)
+		ass.True(ok)
+		ass.Equal(test.field, field)
+	}
+
+	_, ok := formatField(nil)
+	ass.False(ok)
+}
+
+func TestInfluxClient_Write(t *testing.T) {
+	ass := assert.New(t)
+
+	var body []byte
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ass.Equal(http.MethodPost, r.Method)
+		ass.Equal("/write", r.URL.Path)
+		ass.Equal("sunny", r.URL.Query().Get("db"))
+		auth = r.Header.Get("Authorization")
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := InfluxClient{
+		URL:   server.URL + "/write?db=sunny",
+		Token: "secret",
+	}
+	ass.NoError(client.Write(context.Background(), testMeasurement()))
+	ass.Equal("Token secret", auth)
+
+	var expected bytes.Buffer
+	ass.NoError(WriteInflux(&expected, testMeasurement()))
+	ass.Equal(expected.Bytes(), body)
+}
+
+func TestInfluxClient_WriteError(t *testing.T) {
+	ass := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "database not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := InfluxClient{
+		URL: server.URL + "/write?db=unknown",
+	}
+	err := client.Write(context.Background(), testMeasurement())
+	ass.Error(err)
+	ass.Contains(err.Error(), "database not found")
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package export encodes device values to InfluxDB line protocol and CSV.
+package export
+
+import (
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// Measurement contains the values of a single device
+type Measurement struct {
+	Serial uint32
+	Values map[sunny.ValueID]sunny.TimedValue
+}
+
+// NewMeasurement from values without device timestamps (e.g. from GetValues)
+func NewMeasurement(serial uint32, values map[sunny.ValueID]interface{}, t time.Time) Measurement {
+	m := Measurement{
+		Serial: serial,
+		Values: make(map[sunny.ValueID]sunny.TimedValue, len(values)),
+	}
+	for id, value := range values {
+		m.Values[id] = sunny.TimedValue{
+			Value: value,
+			Time:  t,
+		}
+	}
+	return m
+}
+
+// SplitChannel splits the phase (L1-L3) or string (S1-S3) from the value name
+func SplitChannel(id sunny.ValueID) (name, phase, str string) {
+	name = id.String()
+	if len(name) <= 2 {
+		return name, "", ""
+	}
+
+	suffix := name[len(name)-2:]
+	if suffix[1] < '1' || suffix[1] > '3' {
+		return name, "", ""
+	}
+	switch suffix[0] {
+	case 'L':
+		return name[:len(name)-2], suffix, ""
+	case 'S':
+		return name[:len(name)-2], "", suffix[1:]
+	}
+	return name, "", ""
+}
@@ -0,0 +1,43 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+func TestSplitChannel(t *testing.T) {
+	ass := assert.New(t)
+
+	tests := []struct {
+		id               sunny.ValueID
+		name, phase, str string
+	}{
+		{sunny.ActivePowerPlus, "ActivePowerPlus", "", ""},
+		{sunny.ActivePowerPlusL3, "ActivePowerPlus", "L3", ""},
+		{sunny.PowerS2, "Power", "", "2"},
+		{sunny.DeviceName, "DeviceName", "", ""},
+	}
+	for _, test := range tests {
+		name, phase, str := SplitChannel(test.id)
+		ass.Equal(test.name, name)
+		ass.Equal(test.phase, phase)
+		ass.Equal(test.str, str)
+	}
+}
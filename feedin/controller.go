@@ -0,0 +1,256 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package feedin limits the power exported to the grid by controlling the
+// active power limit of an inverter based on the values of an energy meter.
+package feedin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// Meter provides the values of an energy meter (e.g. *sunny.Device)
+type Meter interface {
+	// GetValuesCtx from device
+	GetValuesCtx(ctx context.Context) (map[sunny.ValueID]interface{}, error)
+}
+
+// Inverter allows to set the active power limit (e.g. *sunny.Device)
+type Inverter interface {
+	// SetValueCtx on inverter
+	SetValueCtx(ctx context.Context, id sunny.ValueID, value interface{}) error
+}
+
+// Config of the feed-in controller
+type Config struct {
+	// ExportLimit is the maximum power in W that should be exported to the grid
+	ExportLimit float64
+
+	// MinPower is the lowest active power limit in W that is written
+	MinPower float64
+	// MaxPower is the highest active power limit in W (nominal inverter power)
+	MaxPower float64
+
+	// Kp is the proportional gain of the PI controller
+	Kp float64
+	// Ki is the integral gain of the PI controller (1/s)
+	Ki float64
+
+	// RampUp is the maximum increase of the limit in W/s (0 -> unlimited)
+	RampUp float64
+	// RampDown is the maximum decrease of the limit in W/s (0 -> unlimited)
+	RampDown float64
+
+	// Deadband is the minimum change in W before a new limit is written
+	Deadband float64
+
+	// Interval between two control cycles (default 1 s)
+	Interval time.Duration
+	// Timeout without meter data until the fail-safe limit is written (default 5 s)
+	Timeout time.Duration
+	// FailSafePower is the limit in W written if no meter data are received
+	FailSafePower float64
+}
+
+// Controller limits the grid export with a PI loop
+type Controller struct {
+	config   Config
+	meter    Meter
+	inverter Inverter
+
+	mutex sync.RWMutex
+	// integral part of the PI controller
+	integral float64
+	// last written limit (fail-safe limit until the first write)
+	setpoint float64
+	// true if setpoint was written to the inverter
+	written bool
+
+	// time of last valid meter data
+	lastData time.Time
+	// true if fail-safe limit is active
+	failSafe bool
+}
+
+// NewController creates a new feed-in controller
+func NewController(config Config, meter Meter, inverter Inverter) (*Controller, error) {
+	if config.MaxPower <= 0 {
+		return nil, fmt.Errorf("invalid maximum power %f", config.MaxPower)
+	}
+	if config.MinPower < 0 || config.MinPower > config.MaxPower {
+		return nil, fmt.Errorf("invalid minimum power %f", config.MinPower)
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = time.Second * 5
+	}
+
+	return &Controller{
+		config:   config,
+		meter:    meter,
+		inverter: inverter,
+		// start with the fail-safe limit until the first meter data arrive
+		integral: clamp(config.FailSafePower, config.MinPower, config.MaxPower),
+		setpoint: clamp(config.FailSafePower, config.MinPower, config.MaxPower),
+	}, nil
+}
+
+// Setpoint returns the last written active power limit
+func (c *Controller) Setpoint() float64 {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.setpoint
+}
+
+// FailSafe returns true if the fail-safe limit is active
+func (c *Controller) FailSafe() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.failSafe
+}
+
+// Run the control loop until the context is canceled
+func (c *Controller) Run(ctx context.Context) error {
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+
+	c.lastData = time.Now()
+	last := c.lastData
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, c.config.Interval)
+		values, err := c.meter.GetValuesCtx(readCtx)
+		cancel()
+
+		now := time.Now()
+		c.step(ctx, now, now.Sub(last), values, err)
+		last = now
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// step runs a single control cycle
+func (c *Controller) step(ctx context.Context, now time.Time, dt time.Duration,
+	values map[sunny.ValueID]interface{}, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var export float64
+	if err == nil {
+		export, err = gridExport(values)
+	}
+	if err != nil {
+		sunny.Log.Printf("feedin - no meter data: %v", err)
+
+		if now.Sub(c.lastData) >= c.config.Timeout {
+			c.enterFailSafe(ctx)
+		}
+		return
+	}
+	c.lastData = now
+
+	if c.failSafe {
+		sunny.Log.Printf("feedin - meter data received, leave fail-safe")
+		c.failSafe = false
+	}
+
+	setpoint := c.calculate(export, dt.Seconds())
+	c.write(ctx, setpoint)
+}
+
+// calculate the new setpoint from the actual grid export
+func (c *Controller) calculate(export, dt float64) float64 {
+	// positive error -> inverter can produce more
+	e := c.config.ExportLimit - export
+
+	c.integral += c.config.Ki * e * dt
+	setpoint := clamp(c.config.Kp*e+c.integral, c.config.MinPower, c.config.MaxPower)
+
+	// rate limiting
+	if c.config.RampUp > 0 && setpoint > c.setpoint+c.config.RampUp*dt {
+		setpoint = c.setpoint + c.config.RampUp*dt
+	}
+	if c.config.RampDown > 0 && setpoint < c.setpoint-c.config.RampDown*dt {
+		setpoint = c.setpoint - c.config.RampDown*dt
+	}
+
+	// anti windup -> integral follows the limited setpoint
+	c.integral = clamp(setpoint-c.config.Kp*e, c.config.MinPower, c.config.MaxPower)
+	return setpoint
+}
+
+// enterFailSafe writes the fail-safe limit and resets the PI controller
+func (c *Controller) enterFailSafe(ctx context.Context) {
+	if !c.failSafe {
+		sunny.Log.Printf("feedin - meter timeout, enter fail-safe")
+		c.failSafe = true
+		c.written = false
+	}
+
+	setpoint := clamp(c.config.FailSafePower, c.config.MinPower, c.config.MaxPower)
+	c.integral = setpoint
+	c.write(ctx, setpoint)
+}
+
+// write the setpoint to the inverter if it has changed
+func (c *Controller) write(ctx context.Context, setpoint float64) {
+	limit := math.Round(setpoint)
+	if c.written && (limit == math.Round(c.setpoint) ||
+		math.Abs(setpoint-c.setpoint) < c.config.Deadband) {
+		return
+	}
+
+	writeCtx, cancel := context.WithTimeout(ctx, c.config.Interval)
+	defer cancel()
+	err := c.inverter.SetValueCtx(writeCtx, sunny.ActivePowerLimit, limit)
+	if err != nil {
+		sunny.Log.Printf("feedin - failed to write limit: %v", err)
+		c.written = false
+		return
+	}
+
+	// only a written limit is the base for the next cycle
+	c.setpoint = setpoint
+	c.written = true
+}
+
+// gridExport from energy meter values in W (negative on import)
+func gridExport(values map[sunny.ValueID]interface{}) (float64, error) {
+	minus, ok := values[sunny.ActivePowerMinus].(float64)
+	if !ok {
+		return 0, fmt.Errorf("missing value %s", sunny.ActivePowerMinus)
+	}
+	plus, ok := values[sunny.ActivePowerPlus].(float64)
+	if !ok {
+		return 0, fmt.Errorf("missing value %s", sunny.ActivePowerPlus)
+	}
+	return minus - plus, nil
+}
+
+// clamp value between min and max
+func clamp(value, min, max float64) float64 {
+	return math.Max(min, math.Min(max, value))
+}
@@ -0,0 +1,317 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package feedin
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// simulatedPlant with an inverter and an energy meter at the grid connection
+type simulatedPlant struct {
+	mutex sync.Mutex
+
+	available float64 // available PV power
+	load      float64 // power consumption
+	limit     float64 // active power limit of inverter
+
+	meterOffline bool
+	writeFails   bool
+	writes       int
+}
+
+// GetValuesCtx of simulated energy meter
+func (p *simulatedPlant) GetValuesCtx(ctx context.Context) (map[sunny.ValueID]interface{}, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.meterOffline {
+		return nil, fmt.Errorf("energy meter does not respond")
+	}
+
+	grid := p.load - math.Min(p.available, p.limit)
+	return map[sunny.ValueID]interface{}{
+		sunny.ActivePowerPlus:  math.Max(grid, 0),
+		sunny.ActivePowerMinus: math.Max(-grid, 0),
+	}, nil
+}
+
+// SetValueCtx of simulated inverter
+func (p *simulatedPlant) SetValueCtx(ctx context.Context, id sunny.ValueID, value interface{}) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if id != sunny.ActivePowerLimit {
+		return fmt.Errorf("value %s can not be written", id)
+	}
+	if p.writeFails {
+		return fmt.Errorf("inverter does not respond")
+	}
+	p.limit = value.(float64)
+	p.writes++
+	return nil
+}
+
+func (p *simulatedPlant) export() float64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return math.Min(p.available, p.limit) - p.load
+}
+
+// cycle runs the given amount of control cycles with simulated time
+func cycle(c *Controller, plant *simulatedPlant, start time.Time, count int) time.Time {
+	now := start
+	for i := 0; i < count; i++ {
+		now = now.Add(c.config.Interval)
+		values, err := plant.GetValuesCtx(context.Background())
+		c.step(context.Background(), now, c.config.Interval, values, err)
+	}
+	return now
+}
+
+func TestNewController(t *testing.T) {
+	ass := assert.New(t)
+
+	_, err := NewController(Config{}, nil, nil)
+	ass.Error(err)
+
+	_, err = NewController(Config{MaxPower: 1000, MinPower: 2000}, nil, nil)
+	ass.Error(err)
+
+	c, err := NewController(Config{MaxPower: 1000, FailSafePower: 5000}, nil, nil)
+	ass.NoError(err)
+	ass.Equal(time.Second, c.config.Interval)
+	ass.Equal(time.Second*5, c.config.Timeout)
+	ass.Equal(1000.0, c.Setpoint())
+}
+
+func TestController_ZeroExport(t *testing.T) {
+	ass := assert.New(t)
+
+	plant := &simulatedPlant{
+		available: 5000,
+		load:      1200,
+		limit:     5000,
+	}
+	c, err := NewController(Config{
+		MaxPower: 5000,
+		Kp:       0.3,
+		Ki:       0.5,
+	}, plant, plant)
+	ass.NoError(err)
+
+	cycle(c, plant, time.Now(), 60)
+	ass.InDelta(0, plant.export(), 5)
+	ass.InDelta(1200, c.Setpoint(), 5)
+
+	// load increases -> limit follows
+	plant.load = 2000
+	cycle(c, plant, time.Now(), 60)
+	ass.InDelta(0, plant.export(), 5)
+	ass.InDelta(2000, c.Setpoint(), 5)
+}
+
+func TestController_ExportLimit(t *testing.T) {
+	ass := assert.New(t)
+
+	plant := &simulatedPlant{
+		available: 8000,
+		load:      500,
+		limit:     0,
+	}
+	c, err := NewController(Config{
+		ExportLimit: 3500,
+		MaxPower:    8000,
+		Kp:          0.3,
+		Ki:          0.5,
+	}, plant, plant)
+	ass.NoError(err)
+
+	cycle(c, plant, time.Now(), 60)
+	ass.InDelta(3500, plant.export(), 5)
+
+	// less PV power than allowed export -> no limitation
+	plant.available = 3000
+	cycle(c, plant, time.Now(), 60)
+	ass.InDelta(8000, c.Setpoint(), 1)
+}
+
+func TestController_RateLimit(t *testing.T) {
+	ass := assert.New(t)
+
+	plant := &simulatedPlant{
+		available: 5000,
+		load:      5000,
+	}
+	c, err := NewController(Config{
+		MaxPower: 5000,
+		Kp:       1,
+		Ki:       1,
+		RampUp:   100,
+		RampDown: 200,
+	}, plant, plant)
+	ass.NoError(err)
+
+	cycle(c, plant, time.Now(), 1)
+	ass.InDelta(100, c.Setpoint(), 0.1)
+	cycle(c, plant, time.Now(), 1)
+	ass.InDelta(200, c.Setpoint(), 0.1)
+
+	plant.load = 0
+	cycle(c, plant, time.Now(), 1)
+	ass.InDelta(0, c.Setpoint(), 0.1)
+}
+
+func TestController_Deadband(t *testing.T) {
+	ass := assert.New(t)
+
+	writes := func(deadband float64) int {
+		plant := &simulatedPlant{
+			available: 5000,
+			load:      1000,
+		}
+		c, err := NewController(Config{
+			MaxPower: 5000,
+			Kp:       0.3,
+			Ki:       0.5,
+			Deadband: deadband,
+		}, plant, plant)
+		ass.NoError(err)
+
+		cycle(c, plant, time.Now(), 60)
+		ass.InDelta(0, plant.export(), deadband+1)
+		return plant.writes
+	}
+
+	ass.Less(writes(0), 60)
+	ass.Less(writes(50), 30)
+}
+
+func TestController_Unchanged(t *testing.T) {
+	ass := assert.New(t)
+
+	plant := &simulatedPlant{
+		available: 5000,
+		load:      1000,
+	}
+	c, err := NewController(Config{
+		MaxPower: 5000,
+		Kp:       0.3,
+		Ki:       0.5,
+	}, plant, plant)
+	ass.NoError(err)
+
+	// steady state -> limit is not written again
+	now := cycle(c, plant, time.Now(), 60)
+	writes := plant.writes
+	cycle(c, plant, now, 10)
+	ass.Equal(writes, plant.writes)
+}
+
+func TestController_WriteFailed(t *testing.T) {
+	ass := assert.New(t)
+
+	plant := &simulatedPlant{
+		available: 5000,
+		load:      5000,
+	}
+	c, err := NewController(Config{
+		MaxPower: 5000,
+		Kp:       1,
+		Ki:       1,
+		RampUp:   100,
+	}, plant, plant)
+	ass.NoError(err)
+
+	now := cycle(c, plant, time.Now(), 1)
+	ass.InDelta(100, c.Setpoint(), 0.1)
+
+	// failed writes do not move the base of the rate limit
+	plant.writeFails = true
+	now = cycle(c, plant, now, 3)
+	ass.InDelta(100, c.Setpoint(), 0.1)
+	ass.Equal(100.0, plant.limit)
+
+	plant.writeFails = false
+	cycle(c, plant, now, 1)
+	ass.InDelta(200, c.Setpoint(), 0.1)
+	ass.Equal(200.0, plant.limit)
+}
+
+func TestController_FailSafe(t *testing.T) {
+	ass := assert.New(t)
+
+	plant := &simulatedPlant{
+		available: 5000,
+		load:      3000,
+	}
+	c, err := NewController(Config{
+		MaxPower:      5000,
+		Kp:            0.3,
+		Ki:            0.5,
+		Timeout:       time.Second * 3,
+		FailSafePower: 500,
+	}, plant, plant)
+	ass.NoError(err)
+
+	now := cycle(c, plant, time.Now(), 60)
+	ass.InDelta(3000, c.Setpoint(), 5)
+
+	// meter stops sending -> keep last limit until timeout
+	plant.meterOffline = true
+	now = cycle(c, plant, now, 2)
+	ass.False(c.FailSafe())
+	ass.InDelta(3000, plant.limit, 5)
+
+	now = cycle(c, plant, now, 1)
+	ass.True(c.FailSafe())
+	ass.Equal(500.0, plant.limit)
+
+	// meter is back -> leave fail-safe
+	plant.meterOffline = false
+	cycle(c, plant, now, 60)
+	ass.False(c.FailSafe())
+	ass.InDelta(3000, c.Setpoint(), 5)
+}
+
+func TestController_Run(t *testing.T) {
+	ass := assert.New(t)
+
+	plant := &simulatedPlant{
+		available: 5000,
+		load:      1500,
+		limit:     5000,
+	}
+	c, err := NewController(Config{
+		MaxPower: 5000,
+		Kp:       0.3,
+		Ki:       20,
+		Interval: time.Millisecond * 10,
+	}, plant, plant)
+	ass.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
+	ass.Equal(context.DeadlineExceeded, c.Run(ctx))
+	ass.InDelta(0, plant.export(), 50)
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gitlab.com/bboehmke/sunny/proto/net2"
+)
+
+// identitySusyID used for generated identities (same as net2.LocalDeviceId)
+const identitySusyID = 120
+
+// LoadIdentity reads the identity from the file at path. If the file does
+// not exist a random identity is generated and stored in the file.
+// The file contains "<SusyID>:<serial number>".
+func LoadIdentity(path string) (net2.DeviceId, error) {
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		var id net2.DeviceId
+		_, err = fmt.Sscanf(strings.TrimSpace(string(data)), "%d:%d", &id.SusyID, &id.SerialNumber)
+		if err != nil {
+			return net2.DeviceId{}, fmt.Errorf("invalid identity in %s: %w", path, err)
+		}
+		return id, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return net2.DeviceId{}, err
+	}
+
+	id, err := GenerateIdentity()
+	if err != nil {
+		return net2.DeviceId{}, err
+	}
+	err = ioutil.WriteFile(path, []byte(fmt.Sprintf("%d:%d\n", id.SusyID, id.SerialNumber)), 0600)
+	if err != nil {
+		return net2.DeviceId{}, fmt.Errorf("failed to store identity: %w", err)
+	}
+	return id, nil
+}
+
+// GenerateIdentity with a random serial number
+func GenerateIdentity() (net2.DeviceId, error) {
+	b := make([]byte, 4)
+	for {
+		_, err := rand.Read(b)
+		if err != nil {
+			return net2.DeviceId{}, fmt.Errorf("failed to generate identity: %w", err)
+		}
+
+		// skip reserved serial numbers (broadcast)
+		serial := binary.BigEndian.Uint32(b)
+		if serial != 0 && serial != 0xFFFFFFFF {
+			return net2.DeviceId{
+				SusyID:       identitySusyID,
+				SerialNumber: serial,
+			}, nil
+		}
+	}
+}
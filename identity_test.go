@@ -0,0 +1,130 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny/proto/net2"
+)
+
+func TestGenerateIdentity(t *testing.T) {
+	ass := assert.New(t)
+
+	a, err := GenerateIdentity()
+	ass.NoError(err)
+	b, err := GenerateIdentity()
+	ass.NoError(err)
+
+	ass.Equal(uint16(identitySusyID), a.SusyID)
+	ass.NotEqual(uint32(0), a.SerialNumber)
+	ass.NotEqual(uint32(0xFFFFFFFF), a.SerialNumber)
+	ass.NotEqual(a, b)
+}
+
+func TestLoadIdentity(t *testing.T) {
+	ass := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "sunny")
+	if !ass.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "identity")
+
+	// generated and stored on first load
+	id, err := LoadIdentity(path)
+	ass.NoError(err)
+	ass.Equal(uint16(identitySusyID), id.SusyID)
+
+	data, err := ioutil.ReadFile(path)
+	ass.NoError(err)
+	ass.Equal(fmt.Sprintf("%d:%d\n", id.SusyID, id.SerialNumber), string(data))
+	stat, err := os.Stat(path)
+	if ass.NoError(err) {
+		ass.Equal(os.FileMode(0600), stat.Mode().Perm())
+	}
+
+	// same identity on next load
+	loaded, err := LoadIdentity(path)
+	ass.NoError(err)
+	ass.Equal(id, loaded)
+
+	// written by hand
+	ass.NoError(ioutil.WriteFile(path, []byte(" 125:1234567890 \n"), 0600))
+	loaded, err = LoadIdentity(path)
+	ass.NoError(err)
+	ass.Equal(net2.DeviceId{SusyID: 125, SerialNumber: 1234567890}, loaded)
+}
+
+func TestLoadIdentity_Invalid(t *testing.T) {
+	ass := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "sunny")
+	if !ass.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "identity")
+
+	for _, content := range []string{
+		"",
+		"invalid",
+		"120",
+		"120:",
+		":123",
+		"120:abc",
+		"70000:123",      // SusyID out of range
+		"120:5000000000", // serial number out of range
+	} {
+		ass.NoError(ioutil.WriteFile(path, []byte(content), 0600))
+		_, err := LoadIdentity(path)
+		ass.Error(err, content)
+
+		// invalid file is not overwritten
+		data, err := ioutil.ReadFile(path)
+		ass.NoError(err)
+		ass.Equal(content, string(data))
+	}
+
+	// directory of file does not exist
+	_, err = LoadIdentity(filepath.Join(dir, "missing", "identity"))
+	ass.Error(err)
+}
+
+func TestNewUnicastDeviceWithIdentity(t *testing.T) {
+	ass := assert.New(t)
+	newSimulatedInverter(t)
+
+	identity := net2.DeviceId{SusyID: identitySusyID, SerialNumber: 1234567890}
+	device, err := NewUnicastDeviceWithIdentity(context.Background(), "127.0.0.1", "0000", identity)
+	if !ass.NoError(err) {
+		return
+	}
+	defer device.Close()
+	ass.Equal(identity, device.conn.Identity())
+
+	// responses are addressed to the identity
+	name, err := device.GetValue(DeviceName)
+	ass.NoError(err)
+	ass.Equal("Simulated Inverter", name)
+}
@@ -0,0 +1,199 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DeviceInfo with static information of a device
+type DeviceInfo struct {
+	SerialNumber uint32
+	SusyID       uint16
+	// Name of device (configured by the user)
+	Name string
+
+	// Class tag ID and its name (e.g. 8001 "Solar Inverters")
+	Class     uint32
+	ClassName string
+	// Type tag ID and the model name (e.g. 9404 "SB 5.0-1AV-41")
+	Type  uint32
+	Model string
+	// Product of the catalogue with family and capability hints
+	// (empty if the device is not known)
+	Product Product
+
+	// Firmware version of device
+	Firmware Version
+	// NominalPower of device in W (0 if unknown)
+	NominalPower float64
+
+	EnergyMeter bool
+}
+
+// deviceClasses maps device class tags to names
+var deviceClasses = map[uint32]string{
+	8000: "All Devices",
+	8001: "Solar Inverters",
+	8002: "Wind Turbine Inverter",
+	8007: "Battery Inverters",
+	8033: "Consumer",
+	8064: "Sensor System in General",
+	8065: "Electricity meter",
+	8128: "Communication products",
+}
+
+// energyMeterClass tag of energy meters
+const energyMeterClass = 8065
+
+// Info returns the static information of the device
+// The information is read once and cached for later calls.
+func (d *Device) Info(ctx context.Context) (DeviceInfo, error) {
+	d.mutex.RLock()
+	info := d.info
+	d.mutex.RUnlock()
+	if info != nil {
+		return *info, nil
+	}
+
+	var err error
+	if d.energyMeter {
+		info, err = d.energyMeterInfo(ctx)
+	} else {
+		info, err = d.inverterInfo(ctx)
+	}
+	if err != nil {
+		return DeviceInfo{}, err
+	}
+
+	d.mutex.Lock()
+	d.info = info
+	d.mutex.Unlock()
+	return *info, nil
+}
+
+// energyMeterInfo from the next broadcast of the energy meter
+func (d *Device) energyMeterInfo(ctx context.Context) (*DeviceInfo, error) {
+	values, err := d.GetTimedValuesCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &DeviceInfo{
+		SerialNumber: d.id.SerialNumber,
+		SusyID:       d.id.SusyID,
+		Name:         "Energy Meter",
+		Class:        energyMeterClass,
+		ClassName:    deviceClasses[energyMeterClass],
+		Model:        "Energy Meter",
+		EnergyMeter:  true,
+	}
+	if product, ok := LookupSusyID(d.id.SusyID); ok {
+		info.Model = product.Model
+		info.Product = product
+	}
+	if version, ok := values[SoftwareVersion].Value.(uint32); ok {
+		info.Firmware = DecodeVersion(version)
+	}
+	return info, nil
+}
+
+// inverterInfo requests the information of an inverter
+func (d *Device) inverterInfo(ctx context.Context) (*DeviceInfo, error) {
+	policy := d.policyFor(ctx)
+	ctx, cancel := policy.withTimeout(ctx)
+	defer cancel()
+
+	d.sessionMutex.Lock()
+	defer d.sessionMutex.Unlock()
+
+	err := d.loginRetry(ctx, policy.LoginRetries)
+	if err != nil {
+		return nil, err
+	}
+	defer d.logout()
+
+	values, err := d.requestValuesCached(ctx, getInverterRequest(DeviceName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device information: %w", err)
+	}
+
+	// not provided by all devices
+	for _, id := range []ValueID{SoftwareVersion, ActivePowerMax} {
+		optional, err := d.requestValuesCached(ctx, getInverterRequest(id))
+		if err != nil && !errors.Is(err, ErrNotSupported) {
+			return nil, fmt.Errorf("failed to get device information: %w", err)
+		}
+		for id, value := range optional {
+			values[id] = value
+		}
+	}
+
+	info := &DeviceInfo{
+		SerialNumber: d.id.SerialNumber,
+		SusyID:       d.id.SusyID,
+	}
+	info.Name, _ = values[DeviceName].Value.(string)
+	info.Class, _ = values[DeviceClass].Value.(uint32)
+	info.ClassName = tagName(deviceClasses, info.Class)
+	info.Type, _ = values[DeviceType].Value.(uint32)
+	if product, ok := LookupDeviceType(info.Type); ok {
+		info.Model = product.Model
+		info.Product = product
+	} else if product, ok := LookupSusyID(info.SusyID); ok {
+		info.Model = product.Model
+		info.Product = product
+	} else {
+		info.Model = tagName(nil, info.Type)
+	}
+
+	if version, ok := values[SoftwareVersion].Value.(uint32); ok {
+		info.Firmware = DecodeVersion(version)
+	}
+	if value, ok := values[ActivePowerMax].Value.(uint32); ok {
+		info.NominalPower = float64(value)
+	}
+	return info, nil
+}
+
+// tagName of tag or a generic name if the tag is unknown
+func tagName(names map[uint32]string, tag uint32) string {
+	if name, ok := names[tag]; ok {
+		return name
+	}
+	if tag == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Unknown (%d)", tag)
+}
+
+// requireFirmware returns ErrNotSupported if the firmware of the device is
+// older than min
+func (d *Device) requireFirmware(ctx context.Context, min Version) error {
+	if min.IsZero() {
+		return nil
+	}
+
+	info, err := d.Info(ctx)
+	if err != nil {
+		return err
+	}
+	if info.Firmware.Less(min) {
+		return fmt.Errorf("%w: firmware %s is older than %s", ErrNotSupported, info.Firmware, min)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagName(t *testing.T) {
+	ass := assert.New(t)
+
+	ass.Equal("Solar Inverters", tagName(deviceClasses, 8001))
+	ass.Equal("Unknown (1234)", tagName(deviceClasses, 1234))
+	ass.Equal("", tagName(deviceClasses, 0))
+}
@@ -0,0 +1,339 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"gitlab.com/bboehmke/sunny/proto/net2"
+)
+
+// managedConnection with the networks of its interface
+type managedConnection struct {
+	conn     *Connection
+	networks []*net.IPNet
+}
+
+// Manager handles connections on multiple interfaces
+type Manager struct {
+	connections []managedConnection
+}
+
+// NewManager opens connections on the given interfaces or on all
+// multicast capable interfaces if none is given
+func NewManager(interfaces ...string) (*Manager, error) {
+	return NewManagerWithIdentity(*net2.LocalDeviceId(), interfaces...)
+}
+
+// NewManagerWithIdentity works like NewManager but all connections use the
+// given identity as source of requests (see NewConnectionWithIdentity)
+func NewManagerWithIdentity(identity net2.DeviceId, interfaces ...string) (*Manager, error) {
+	if len(interfaces) == 0 {
+		var err error
+		interfaces, err = multicastInterfaces()
+		if err != nil {
+			return nil, err
+		}
+		if len(interfaces) == 0 {
+			return nil, fmt.Errorf("no multicast capable interface found")
+		}
+	}
+
+	var manager Manager
+	for _, name := range interfaces {
+		inf, err := net.InterfaceByName(name)
+		if err != nil {
+			manager.Close()
+			return nil, err
+		}
+		addrs, err := inf.Addrs()
+		if err != nil {
+			manager.Close()
+			return nil, fmt.Errorf("failed to get addresses of %s: %w", name, err)
+		}
+
+		conn, err := NewConnectionWithIdentity(name, identity)
+		if err != nil {
+			manager.Close()
+			return nil, fmt.Errorf("failed to open connection on %s: %w", name, err)
+		}
+
+		entry := managedConnection{conn: conn}
+		for _, addr := range addrs {
+			if network, ok := addr.(*net.IPNet); ok && network.IP.To4() != nil {
+				entry.networks = append(entry.networks, network)
+			}
+		}
+		manager.connections = append(manager.connections, entry)
+	}
+	return &manager, nil
+}
+
+// multicastInterfaces returns the names of all up and multicast capable
+// interfaces except loopback
+func multicastInterfaces() ([]string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, inf := range interfaces {
+		if inf.Flags&net.FlagUp == 0 ||
+			inf.Flags&net.FlagMulticast == 0 ||
+			inf.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		names = append(names, inf.Name)
+	}
+	return names, nil
+}
+
+// Close all connections of the manager
+// Note: the connections are shared with NewConnection calls for the same
+// interface and identity which must not be used afterwards.
+func (m *Manager) Close() {
+	for _, entry := range m.connections {
+		entry.conn.closeMulticast()
+	}
+	m.connections = nil
+}
+
+// Connections of the manager
+func (m *Manager) Connections() []*Connection {
+	connections := make([]*Connection, 0, len(m.connections))
+	for _, entry := range m.connections {
+		connections = append(connections, entry.conn)
+	}
+	return connections
+}
+
+// route returns the connection of the interface the IP address belongs to
+// or nil if no interface network contains the address
+func (m *Manager) route(address string) *Connection {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil
+	}
+	for _, entry := range m.connections {
+		for _, network := range entry.networks {
+			if network.Contains(ip) {
+				return entry.conn
+			}
+		}
+	}
+	return nil
+}
+
+// NewDevice creates a device on the connection of the interface the
+// address belongs to. If no interface matches all connections are tried.
+func (m *Manager) NewDevice(address, password string) (*Device, error) {
+	ip, err := net.ResolveIPAddr("ip4", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve address: %w", err)
+	}
+
+	if conn := m.route(ip.String()); conn != nil {
+		return conn.NewDevice(ip.String(), password)
+	}
+
+	var lastErr error
+	for _, entry := range m.connections {
+		device, err := entry.conn.NewDevice(ip.String(), password)
+		if err == nil {
+			return device, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// managerResult is a discovery result of a single connection
+type managerResult struct {
+	conn   *Connection
+	result discoveryResult
+}
+
+// discoveryFilter reports devices received on multiple interfaces only once
+type discoveryFilter struct {
+	mutex   sync.Mutex
+	ips     map[string]bool
+	serials map[uint32]bool
+}
+
+// newDiscoveryFilter without known devices
+func newDiscoveryFilter() *discoveryFilter {
+	return &discoveryFilter{
+		ips:     make(map[string]bool),
+		serials: make(map[uint32]bool),
+	}
+}
+
+// claimIP returns true if the IP address was not claimed before
+func (f *discoveryFilter) claimIP(ip string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.ips[ip] {
+		return false
+	}
+	f.ips[ip] = true
+	return true
+}
+
+// releaseIP so it can be claimed again (e.g. after a failed ping)
+func (f *discoveryFilter) releaseIP(ip string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.ips, ip)
+}
+
+// claimSerial returns true if the serial number was not claimed before
+// (same device reachable via multiple addresses)
+func (f *discoveryFilter) claimSerial(serial uint32) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.serials[serial] {
+		return false
+	}
+	f.serials[serial] = true
+	return true
+}
+
+// DiscoverDevices on all interfaces until the context is done
+// Devices are created on the connection of their interface and are only
+// reported once even if they are received on multiple interfaces.
+func (m *Manager) DiscoverDevices(ctx context.Context, devices chan *Device, password string) {
+	results := make(chan managerResult, 10)
+
+	// forward discovery results of all connections
+	var forwardWg sync.WaitGroup
+	for _, entry := range m.connections {
+		discoverCh := make(chan discoveryResult, 10)
+		entry.conn.registerDiscoverer(discoverCh)
+		defer entry.conn.unregisterDiscoverer(discoverCh)
+
+		forwardWg.Add(1)
+		go func(conn *Connection) {
+			defer forwardWg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case result := <-discoverCh:
+					results <- managerResult{conn: conn, result: result}
+				}
+			}
+		}(entry.conn)
+	}
+
+	var wg sync.WaitGroup
+	filter := newDiscoveryFilter()
+
+	ticker := time.NewTicker(time.Millisecond * 500)
+	m.sendDiscoveryRequests()
+loop:
+	for {
+		select {
+		case <-ctx.Done():
+			break loop
+
+		case r := <-results:
+			if !filter.claimIP(r.result.ip) {
+				continue
+			}
+
+			conn := m.route(r.result.ip)
+			if conn == nil {
+				conn = r.conn
+			}
+
+			wg.Add(1)
+			go func(conn *Connection, result discoveryResult) {
+				defer wg.Done()
+
+				device, err := conn.newDiscoveredDevice(result, password)
+				if err != nil {
+					Log.Printf("discover - skip ip %s: %v", result.ip, err)
+
+					// allow retry with next response
+					filter.releaseIP(result.ip)
+					return
+				}
+
+				if !filter.claimSerial(device.SerialNumber()) {
+					Log.Printf("discover - skip duplicate device %d at %s", device.SerialNumber(), result.ip)
+					device.Close()
+					return
+				}
+
+				Log.Printf("found device %d at %s on %s", device.SerialNumber(), result.ip, conn.Interface())
+				devices <- device
+			}(conn, r.result)
+
+		case <-ticker.C:
+			m.sendDiscoveryRequests()
+		}
+	}
+	ticker.Stop()
+	wg.Wait()
+
+	// release forwarders that wait for a free slot
+	go func() {
+		for range results {
+		}
+	}()
+	forwardWg.Wait()
+	close(results)
+}
+
+// SimpleDiscoverDevices on all interfaces with a simpler interface
+// The devices are ordered by serial number.
+func (m *Manager) SimpleDiscoverDevices(password string) []*Device {
+	devices := make(chan *Device, 10)
+	var deviceList []*Device
+	done := make(chan struct{})
+	go func() {
+		for device := range devices {
+			deviceList = append(deviceList, device)
+		}
+		close(done)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	m.DiscoverDevices(ctx, devices, password)
+	cancel()
+
+	close(devices)
+	<-done
+
+	sort.Slice(deviceList, func(i, j int) bool {
+		return deviceList[i].SerialNumber() < deviceList[j].SerialNumber()
+	})
+	return deviceList
+}
+
+// sendDiscoveryRequests on all connections
+func (m *Manager) sendDiscoveryRequests() {
+	for _, entry := range m.connections {
+		entry.conn.sendDiscoveryRequest()
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny/proto/net2"
+)
+
+// mustParseCIDR for test networks
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+func TestManager_Route(t *testing.T) {
+	ass := assert.New(t)
+
+	eth0 := &Connection{inf: "eth0"}
+	eth1 := &Connection{inf: "eth1"}
+	manager := Manager{
+		connections: []managedConnection{
+			{conn: eth0, networks: []*net.IPNet{mustParseCIDR("192.168.1.0/24")}},
+			{conn: eth1, networks: []*net.IPNet{
+				mustParseCIDR("10.0.0.0/8"),
+				mustParseCIDR("192.168.2.0/24"),
+			}},
+		},
+	}
+
+	tests := []struct {
+		address string
+		conn    *Connection
+	}{
+		{"192.168.1.10", eth0},
+		{"192.168.1.255", eth0},
+		{"192.168.2.10", eth1},
+		{"10.20.30.40", eth1},
+		{"192.168.3.10", nil},
+		{"172.16.0.1", nil},
+		{"invalid", nil},
+		{"", nil},
+	}
+	for _, test := range tests {
+		ass.Equal(test.conn, manager.route(test.address), test.address)
+	}
+
+	// first matching interface wins for overlapping networks
+	manager.connections = append(manager.connections, managedConnection{
+		conn:     &Connection{inf: "eth2"},
+		networks: []*net.IPNet{mustParseCIDR("192.168.0.0/16")},
+	})
+	ass.Equal(eth0, manager.route("192.168.1.10"))
+	ass.Equal("eth2", manager.route("192.168.5.10").Interface())
+}
+
+func TestDiscoveryFilter(t *testing.T) {
+	ass := assert.New(t)
+
+	filter := newDiscoveryFilter()
+	ass.True(filter.claimIP("10.0.0.1"))
+	ass.False(filter.claimIP("10.0.0.1"))
+	ass.True(filter.claimIP("10.0.0.2"))
+
+	// failed ping -> next response is handled again
+	filter.releaseIP("10.0.0.1")
+	ass.True(filter.claimIP("10.0.0.1"))
+
+	// same device via different addresses is reported once
+	ass.True(filter.claimSerial(2000000001))
+	ass.False(filter.claimSerial(2000000001))
+	ass.True(filter.claimSerial(2000000002))
+}
+
+func TestDiscoveryFilter_Concurrent(t *testing.T) {
+	ass := assert.New(t)
+
+	filter := newDiscoveryFilter()
+	var ips, serials int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if filter.claimIP("10.0.0.1") {
+				atomic.AddInt32(&ips, 1)
+			}
+			if filter.claimSerial(1) {
+				atomic.AddInt32(&serials, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	ass.Equal(int32(1), ips)
+	ass.Equal(int32(1), serials)
+}
+
+func TestNewManagerWithIdentity(t *testing.T) {
+	ass := assert.New(t)
+
+	interfaces, err := multicastInterfaces()
+	if err != nil || len(interfaces) == 0 {
+		t.Skip("no multicast capable interface")
+	}
+	identity := net2.DeviceId{SusyID: 125, SerialNumber: 3900000042}
+	key := connectionKey(interfaces[0], identity)
+
+	// connections of previous interfaces are closed on error
+	_, err = NewManagerWithIdentity(identity, interfaces[0], "invalid-interface")
+	ass.Error(err)
+	connectionMutex.Lock()
+	_, ok := connections[key]
+	connectionMutex.Unlock()
+	ass.False(ok)
+
+	manager, err := NewManagerWithIdentity(identity, interfaces[0])
+	if err != nil {
+		t.Skipf("failed to open connection: %v", err)
+	}
+	ass.Len(manager.Connections(), 1)
+	ass.Equal(identity, manager.Connections()[0].Identity())
+
+	manager.Close()
+	ass.Empty(manager.Connections())
+	connectionMutex.Lock()
+	_, ok = connections[key]
+	connectionMutex.Unlock()
+	ass.False(ok)
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Client for Modbus TCP (requests are executed sequentially)
+type Client struct {
+	address string
+
+	mutex         sync.Mutex
+	conn          net.Conn
+	reader        *bufio.Reader
+	transactionID uint16
+}
+
+// NewClient for the given TCP address (connection is established on first request)
+func NewClient(address string) *Client {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "502")
+	}
+	return &Client{
+		address: address,
+	}
+}
+
+// Address of the server
+func (c *Client) Address() string {
+	return c.address
+}
+
+// Close connection to server
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closeConn()
+}
+
+// ReadHoldingRegisters of unit starting at address
+func (c *Client) ReadHoldingRegisters(ctx context.Context, unit uint8, address, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(ctx, FuncReadHoldingRegisters, unit, address, quantity)
+}
+
+// ReadInputRegisters of unit starting at address
+func (c *Client) ReadInputRegisters(ctx context.Context, unit uint8, address, quantity uint16) ([]uint16, error) {
+	return c.readRegisters(ctx, FuncReadInputRegisters, unit, address, quantity)
+}
+
+// readRegisters with the given function
+func (c *Client) readRegisters(ctx context.Context, function, unit uint8, address, quantity uint16) ([]uint16, error) {
+	if quantity == 0 || quantity > maxReadQuantity {
+		return nil, fmt.Errorf("invalid register quantity %d", quantity)
+	}
+
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint16(data[0:], address)
+	binary.BigEndian.PutUint16(data[2:], quantity)
+
+	response, err := c.execute(ctx, &frame{
+		Unit:     unit,
+		Function: function,
+		Data:     data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Data) < 1 || int(response.Data[0]) != len(response.Data)-1 ||
+		int(response.Data[0]) != int(quantity)*2 {
+		return nil, fmt.Errorf("invalid modbus response length")
+	}
+	return decodeRegisters(response.Data[1:]), nil
+}
+
+// execute request and wait for response
+func (c *Client) execute(ctx context.Context, request *frame) (*frame, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// apply deadline of context to connection
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Time{}
+	}
+	_ = c.conn.SetDeadline(deadline)
+
+	c.transactionID++
+	request.TransactionID = c.transactionID
+	_, err = c.conn.Write(request.Bytes())
+	if err != nil {
+		_ = c.closeConn()
+		return nil, err
+	}
+
+	for {
+		response, err := readFrame(c.reader)
+		if err != nil {
+			_ = c.closeConn()
+			return nil, err
+		}
+
+		// skip responses of previous (timed out) requests
+		if response.TransactionID != request.TransactionID {
+			continue
+		}
+		if response.Unit != request.Unit || response.Function&0x7F != request.Function {
+			return nil, fmt.Errorf("unexpected modbus response")
+		}
+
+		if response.Function&0x80 != 0 {
+			if len(response.Data) != 1 {
+				return nil, fmt.Errorf("invalid modbus exception response")
+			}
+			return nil, Exception(response.Data[0])
+		}
+		return response, nil
+	}
+}
+
+// connect to server if not already connected
+func (c *Client) connect(ctx context.Context) error {
+	if c.conn != nil {
+		return nil
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", c.address, err)
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+	return nil
+}
+
+// closeConn if connected
+func (c *Client) closeConn() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	c.reader = nil
+	return err
+}
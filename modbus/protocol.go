@@ -0,0 +1,133 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package modbus implements the parts of Modbus TCP that are required to
+// provide and read device registers.
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Function codes
+const (
+	FuncReadHoldingRegisters uint8 = 0x03
+	FuncReadInputRegisters   uint8 = 0x04
+)
+
+// maximum amount of registers in one read request
+const maxReadQuantity = 125
+
+// Exception code returned by a device
+type Exception uint8
+
+// Known exception codes
+const (
+	ErrIllegalFunction     Exception = 0x01
+	ErrIllegalDataAddress  Exception = 0x02
+	ErrIllegalDataValue    Exception = 0x03
+	ErrServerDeviceFailure Exception = 0x04
+	ErrServerDeviceBusy    Exception = 0x06
+	ErrGatewayTargetFailed Exception = 0x0B
+)
+
+// Error returns the description of the exception
+func (e Exception) Error() string {
+	switch e {
+	case ErrIllegalFunction:
+		return "modbus: illegal function"
+	case ErrIllegalDataAddress:
+		return "modbus: illegal data address"
+	case ErrIllegalDataValue:
+		return "modbus: illegal data value"
+	case ErrServerDeviceFailure:
+		return "modbus: server device failure"
+	case ErrServerDeviceBusy:
+		return "modbus: server device busy"
+	case ErrGatewayTargetFailed:
+		return "modbus: gateway target device failed to respond"
+	default:
+		return fmt.Sprintf("modbus: exception 0x%02X", uint8(e))
+	}
+}
+
+// frame of Modbus TCP (MBAP header + PDU)
+type frame struct {
+	TransactionID uint16
+	Unit          uint8
+	Function      uint8
+	Data          []byte
+}
+
+// Bytes returns binary data
+func (f *frame) Bytes() []byte {
+	data := make([]byte, 8+len(f.Data))
+	binary.BigEndian.PutUint16(data[0:], f.TransactionID)
+	binary.BigEndian.PutUint16(data[2:], 0) // protocol ID
+	binary.BigEndian.PutUint16(data[4:], uint16(2+len(f.Data)))
+	data[6] = f.Unit
+	data[7] = f.Function
+	copy(data[8:], f.Data)
+	return data
+}
+
+// readFrame from reader
+func readFrame(r io.Reader) (*frame, error) {
+	header := make([]byte, 7)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol := binary.BigEndian.Uint16(header[2:]); protocol != 0 {
+		return nil, fmt.Errorf("invalid modbus protocol ID %d", protocol)
+	}
+	length := int(binary.BigEndian.Uint16(header[4:]))
+	if length < 2 || length > 254 {
+		return nil, fmt.Errorf("invalid modbus frame length %d", length)
+	}
+
+	pdu := make([]byte, length-1)
+	_, err = io.ReadFull(r, pdu)
+	if err != nil {
+		return nil, err
+	}
+
+	return &frame{
+		TransactionID: binary.BigEndian.Uint16(header[0:]),
+		Unit:          header[6],
+		Function:      pdu[0],
+		Data:          pdu[1:],
+	}, nil
+}
+
+// encodeRegisters to binary data
+func encodeRegisters(registers []uint16) []byte {
+	data := make([]byte, len(registers)*2)
+	for i, reg := range registers {
+		binary.BigEndian.PutUint16(data[i*2:], reg)
+	}
+	return data
+}
+
+// decodeRegisters from binary data
+func decodeRegisters(data []byte) []uint16 {
+	registers := make([]uint16, len(data)/2)
+	for i := range registers {
+		registers[i] = binary.BigEndian.Uint16(data[i*2:])
+	}
+	return registers
+}
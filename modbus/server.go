@@ -0,0 +1,237 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modbus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+)
+
+// Handler provides the registers of the units behind a server
+type Handler interface {
+	// ReadHoldingRegisters returns quantity registers starting at address
+	ReadHoldingRegisters(unit uint8, address, quantity uint16) ([]uint16, error)
+	// ReadInputRegisters returns quantity registers starting at address
+	ReadInputRegisters(unit uint8, address, quantity uint16) ([]uint16, error)
+}
+
+// Server for Modbus TCP
+type Server struct {
+	Handler Handler
+
+	mutex    sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+}
+
+// ListenAndServe listens on the TCP address and handles requests
+func (s *Server) ListenAndServe(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve accepts connections on the listener until it is closed
+func (s *Server) Serve(listener net.Listener) error {
+	s.mutex.Lock()
+	s.listener = listener
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.mutex.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Temporary() {
+				continue
+			}
+			return err
+		}
+
+		s.mutex.Lock()
+		s.conns[conn] = struct{}{}
+		s.mutex.Unlock()
+
+		go s.handleConn(conn)
+	}
+}
+
+// Close listener and all open connections
+func (s *Server) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// handleConn handles requests of a single connection
+func (s *Server) handleConn(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+		s.mutex.Lock()
+		delete(s.conns, conn)
+		s.mutex.Unlock()
+	}()
+
+	reader := bufio.NewReader(conn)
+	for {
+		request, err := readFrame(reader)
+		if err != nil {
+			return
+		}
+
+		response := s.handleRequest(request)
+		_, err = conn.Write(response.Bytes())
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handleRequest and create response frame
+func (s *Server) handleRequest(request *frame) *frame {
+	response := &frame{
+		TransactionID: request.TransactionID,
+		Unit:          request.Unit,
+		Function:      request.Function,
+	}
+
+	data, err := s.handlePDU(request)
+	if err != nil {
+		exception, ok := err.(Exception)
+		if !ok {
+			exception = ErrServerDeviceFailure
+		}
+		response.Function |= 0x80
+		response.Data = []byte{uint8(exception)}
+		return response
+	}
+	response.Data = data
+	return response
+}
+
+// handlePDU of request and returns response data
+func (s *Server) handlePDU(request *frame) ([]byte, error) {
+	var read func(unit uint8, address, quantity uint16) ([]uint16, error)
+	switch request.Function {
+	case FuncReadHoldingRegisters:
+		read = s.Handler.ReadHoldingRegisters
+	case FuncReadInputRegisters:
+		read = s.Handler.ReadInputRegisters
+	default:
+		return nil, ErrIllegalFunction
+	}
+
+	if len(request.Data) != 4 {
+		return nil, ErrIllegalDataValue
+	}
+	address := binary.BigEndian.Uint16(request.Data[0:])
+	quantity := binary.BigEndian.Uint16(request.Data[2:])
+	if quantity == 0 || quantity > maxReadQuantity {
+		return nil, ErrIllegalDataValue
+	}
+
+	registers, err := read(request.Unit, address, quantity)
+	if err != nil {
+		return nil, err
+	}
+	if len(registers) != int(quantity) {
+		return nil, ErrServerDeviceFailure
+	}
+
+	return append([]byte{uint8(quantity * 2)}, encodeRegisters(registers)...), nil
+}
+
+// RegisterMap is a simple Handler with static registers per unit
+type RegisterMap struct {
+	mutex   sync.RWMutex
+	holding map[uint8]map[uint16]uint16
+	input   map[uint8]map[uint16]uint16
+}
+
+// NewRegisterMap creates an empty register map
+func NewRegisterMap() *RegisterMap {
+	return &RegisterMap{
+		holding: make(map[uint8]map[uint16]uint16),
+		input:   make(map[uint8]map[uint16]uint16),
+	}
+}
+
+// SetHoldingRegisters of unit starting at address
+func (m *RegisterMap) SetHoldingRegisters(unit uint8, address uint16, registers []uint16) {
+	m.set(m.holding, unit, address, registers)
+}
+
+// SetInputRegisters of unit starting at address
+func (m *RegisterMap) SetInputRegisters(unit uint8, address uint16, registers []uint16) {
+	m.set(m.input, unit, address, registers)
+}
+
+// ReadHoldingRegisters returns quantity registers starting at address
+func (m *RegisterMap) ReadHoldingRegisters(unit uint8, address, quantity uint16) ([]uint16, error) {
+	return m.get(m.holding, unit, address, quantity)
+}
+
+// ReadInputRegisters returns quantity registers starting at address
+func (m *RegisterMap) ReadInputRegisters(unit uint8, address, quantity uint16) ([]uint16, error) {
+	return m.get(m.input, unit, address, quantity)
+}
+
+// set registers in the given map
+func (m *RegisterMap) set(registers map[uint8]map[uint16]uint16, unit uint8, address uint16, values []uint16) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := registers[unit]; !ok {
+		registers[unit] = make(map[uint16]uint16)
+	}
+	for i, value := range values {
+		registers[unit][address+uint16(i)] = value
+	}
+}
+
+// get registers from the given map
+func (m *RegisterMap) get(registers map[uint8]map[uint16]uint16, unit uint8, address, quantity uint16) ([]uint16, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	unitRegisters, ok := registers[unit]
+	if !ok {
+		return nil, ErrGatewayTargetFailed
+	}
+
+	values := make([]uint16, quantity)
+	for i := range values {
+		value, ok := unitRegisters[address+uint16(i)]
+		if !ok {
+			return nil, ErrIllegalDataAddress
+		}
+		values[i] = value
+	}
+	return values, nil
+}
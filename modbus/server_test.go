@@ -0,0 +1,96 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modbus
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// request sends a read request and returns the response frame
+func request(t *testing.T, conn net.Conn, reader *bufio.Reader, unit, function uint8, data []byte) *frame {
+	req := &frame{TransactionID: 0x1234, Unit: unit, Function: function, Data: data}
+	_, err := conn.Write(req.Bytes())
+	assert.NoError(t, err)
+
+	response, err := readFrame(reader)
+	assert.NoError(t, err)
+	return response
+}
+
+func TestServer(t *testing.T) {
+	ass := assert.New(t)
+
+	registers := NewRegisterMap()
+	registers.SetHoldingRegisters(1, 100, []uint16{0x0102, 0x0304, 0x0506})
+	registers.SetInputRegisters(1, 10, []uint16{42})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	ass.NoError(err)
+	server := &Server{Handler: registers}
+	done := make(chan error)
+	go func() {
+		done <- server.Serve(listener)
+	}()
+	defer func() {
+		ass.NoError(server.Close())
+		ass.Error(<-done)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	ass.NoError(err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	// holding registers
+	response := request(t, conn, reader, 1, FuncReadHoldingRegisters, []byte{0, 100, 0, 3})
+	ass.Equal(uint16(0x1234), response.TransactionID)
+	ass.Equal(uint8(1), response.Unit)
+	ass.Equal(FuncReadHoldingRegisters, response.Function)
+	ass.Equal([]byte{6, 1, 2, 3, 4, 5, 6}, response.Data)
+
+	// input registers
+	response = request(t, conn, reader, 1, FuncReadInputRegisters, []byte{0, 10, 0, 1})
+	ass.Equal(FuncReadInputRegisters, response.Function)
+	ass.Equal([]uint16{42}, decodeRegisters(response.Data[1:]))
+
+	// read beyond known registers
+	response = request(t, conn, reader, 1, FuncReadHoldingRegisters, []byte{0, 101, 0, 3})
+	ass.Equal(FuncReadHoldingRegisters|0x80, response.Function)
+	ass.Equal([]byte{uint8(ErrIllegalDataAddress)}, response.Data)
+
+	// unknown unit
+	response = request(t, conn, reader, 2, FuncReadHoldingRegisters, []byte{0, 100, 0, 1})
+	ass.Equal([]byte{uint8(ErrGatewayTargetFailed)}, response.Data)
+
+	// invalid quantity
+	response = request(t, conn, reader, 1, FuncReadHoldingRegisters, []byte{0, 100, 0, 0})
+	ass.Equal([]byte{uint8(ErrIllegalDataValue)}, response.Data)
+
+	// unsupported function
+	response = request(t, conn, reader, 1, 0x06, []byte{0, 100, 0, 1})
+	ass.Equal(uint8(0x86), response.Function)
+	ass.Equal([]byte{uint8(ErrIllegalFunction)}, response.Data)
+}
+
+func TestException_Error(t *testing.T) {
+	ass := assert.New(t)
+
+	ass.Equal("modbus: illegal data address", ErrIllegalDataAddress.Error())
+	ass.Equal("modbus: exception 0x42", Exception(0x42).Error())
+}
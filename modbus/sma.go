@@ -0,0 +1,278 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modbus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// DefaultUnit of SMA inverters
+const DefaultUnit uint8 = 3
+
+// registerType of SMA Modbus registers
+type registerType uint8
+
+// SMA register types
+const (
+	typeU32 registerType = iota
+	typeS32
+	typeU64
+)
+
+// size of register type in registers
+func (t registerType) size() uint16 {
+	if t == typeU64 {
+		return 4
+	}
+	return 2
+}
+
+// smaRegisterDef defines a value in the SMA Modbus register map
+type smaRegisterDef struct {
+	Address uint16
+	Type    registerType
+	ID      sunny.ValueID
+	Factor  float64
+}
+
+// smaRegisters contains all values that can be read from SMA inverters
+var smaRegisters = []smaRegisterDef{
+	{30051, typeU32, sunny.DeviceClass, 0},
+	{30053, typeU32, sunny.DeviceType, 0},
+	{30201, typeU32, sunny.DeviceStatus, 0},
+	{30217, typeU32, sunny.DeviceGridRelay, 0},
+	{30513, typeU64, sunny.ActiveEnergyPlus, 3600},
+	{30517, typeU64, sunny.ActiveEnergyPlusToday, 3600},
+	{30521, typeU64, sunny.TimeOperating, 0},
+	{30525, typeU64, sunny.TimeFeed, 0},
+	{30769, typeS32, sunny.CurrentS1, 0.001},
+	{30771, typeS32, sunny.VoltageS1, 0.01},
+	{30773, typeS32, sunny.PowerS1, 0},
+	{30775, typeS32, sunny.ActivePowerPlus, 0},
+	{30777, typeS32, sunny.ActivePowerPlusL1, 0},
+	{30779, typeS32, sunny.ActivePowerPlusL2, 0},
+	{30781, typeS32, sunny.ActivePowerPlusL3, 0},
+	{30783, typeU32, sunny.VoltageL1, 0.01},
+	{30785, typeU32, sunny.VoltageL2, 0.01},
+	{30787, typeU32, sunny.VoltageL3, 0.01},
+	{30803, typeU32, sunny.UtilityFrequency, 0.01},
+	{30953, typeS32, sunny.DeviceTemperature, 0.1},
+	{30957, typeS32, sunny.CurrentS2, 0.001},
+	{30959, typeS32, sunny.VoltageS2, 0.01},
+	{30961, typeS32, sunny.PowerS2, 0},
+	{30977, typeS32, sunny.CurrentL1, 0.001},
+	{30979, typeS32, sunny.CurrentL2, 0.001},
+	{30981, typeS32, sunny.CurrentL3, 0.001},
+}
+
+// smaRegisterSerial contains the serial number (U32)
+const smaRegisterSerial uint16 = 30057
+
+// smaRegisterBlock is a range of registers read with one request
+type smaRegisterBlock struct {
+	Address  uint16
+	Quantity uint16
+	Defs     []smaRegisterDef
+}
+
+// smaRegisterBlocks combines adjacent register definitions to reduce the
+// request amount (gaps are not read to prevent illegal address exceptions)
+func smaRegisterBlocks(defs []smaRegisterDef) []smaRegisterBlock {
+	sorted := append([]smaRegisterDef{}, defs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Address < sorted[j].Address
+	})
+
+	var blocks []smaRegisterBlock
+	for _, def := range sorted {
+		end := def.Address + def.Type.size()
+		if len(blocks) > 0 {
+			block := &blocks[len(blocks)-1]
+			if def.Address == block.Address+block.Quantity && end-block.Address <= maxReadQuantity {
+				block.Quantity = end - block.Address
+				block.Defs = append(block.Defs, def)
+				continue
+			}
+		}
+		blocks = append(blocks, smaRegisterBlock{
+			Address:  def.Address,
+			Quantity: def.Type.size(),
+			Defs:     []smaRegisterDef{def},
+		})
+	}
+	return blocks
+}
+
+// smaRegisterBlocksAll contains the blocks of all known registers
+var smaRegisterBlocksAll = smaRegisterBlocks(smaRegisters)
+
+// parseSMAValue from registers (returns nil for NaN values)
+func parseSMAValue(def smaRegisterDef, registers []uint16) interface{} {
+	var value interface{}
+	switch def.Type {
+	case typeU32:
+		v := uint32(registers[0])<<16 | uint32(registers[1])
+		if v == 0xFFFFFFFF || v == 0x00FFFFFD {
+			return nil
+		}
+		value = v
+	case typeS32:
+		v := int32(uint32(registers[0])<<16 | uint32(registers[1]))
+		if v == math.MinInt32 {
+			return nil
+		}
+		if def.Factor != 0 {
+			return float64(v) * def.Factor
+		}
+		if v < 0 {
+			v = 0 // values without factor are unsigned on Speedwire
+		}
+		return uint32(v)
+	case typeU64:
+		v := uint64(registers[0])<<48 | uint64(registers[1])<<32 |
+			uint64(registers[2])<<16 | uint64(registers[3])
+		if v == 0xFFFFFFFFFFFFFFFF || v == 0x8000000000000000 {
+			return nil
+		}
+		value = v
+	}
+
+	if def.Factor != 0 {
+		switch v := value.(type) {
+		case uint32:
+			return float64(v) * def.Factor
+		case uint64:
+			return float64(v) * def.Factor
+		}
+	}
+	return value
+}
+
+// Device is a SMA inverter that is read via Modbus TCP
+type Device struct {
+	client *Client
+	unit   uint8
+	serial uint32
+}
+
+// NewDevice connects to the SMA inverter at address (host or host:port) and
+// reads the serial number to check the connection
+func NewDevice(address string, unit uint8) (*Device, error) {
+	device := Device{
+		client: NewClient(address),
+		unit:   unit,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	registers, err := device.client.ReadHoldingRegisters(ctx, unit, smaRegisterSerial, 2)
+	if err != nil {
+		_ = device.client.Close()
+		return nil, fmt.Errorf("failed to read serial number of %s: %w", address, err)
+	}
+	device.serial = uint32(registers[0])<<16 | uint32(registers[1])
+
+	sunny.Log.Printf("new modbus inverter at %s - Serial=%d", device.client.Address(), device.serial)
+	return &device, nil
+}
+
+// Close connection to device
+func (d *Device) Close() {
+	_ = d.client.Close()
+}
+
+// SerialNumber of device
+func (d *Device) SerialNumber() uint32 {
+	return d.serial
+}
+
+// Address of device
+func (d *Device) Address() string {
+	return d.client.Address()
+}
+
+// IsEnergyMeter returns always false (energy meters have no Modbus interface)
+func (d *Device) IsEnergyMeter() bool {
+	return false
+}
+
+// GetValueCtx from device and returns nil if value does not exist
+// If the value is not provided via Modbus sunny.ErrNotSupported is returned.
+func (d *Device) GetValueCtx(ctx context.Context, id sunny.ValueID) (interface{}, error) {
+	for _, def := range smaRegisters {
+		if def.ID != id {
+			continue
+		}
+
+		values, err := d.readBlocks(ctx, smaRegisterBlocks([]smaRegisterDef{def}))
+		if err != nil {
+			return nil, err
+		}
+		return values[id].Value, nil
+	}
+	return nil, fmt.Errorf("%w: value %s", sunny.ErrNotSupported, id)
+}
+
+// GetValuesCtx returns all values from device
+func (d *Device) GetValuesCtx(ctx context.Context) (map[sunny.ValueID]interface{}, error) {
+	values, err := d.GetTimedValuesCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[sunny.ValueID]interface{}, len(values))
+	for id, value := range values {
+		data[id] = value.Value
+	}
+	return data, nil
+}
+
+// GetTimedValuesCtx returns all values from device with the time of the request
+func (d *Device) GetTimedValuesCtx(ctx context.Context) (map[sunny.ValueID]sunny.TimedValue, error) {
+	return d.readBlocks(ctx, smaRegisterBlocksAll)
+}
+
+// readBlocks of registers and parse the values
+func (d *Device) readBlocks(ctx context.Context, blocks []smaRegisterBlock) (map[sunny.ValueID]sunny.TimedValue, error) {
+	values := make(map[sunny.ValueID]sunny.TimedValue)
+	for _, block := range blocks {
+		registers, err := d.client.ReadHoldingRegisters(ctx, d.unit, block.Address, block.Quantity)
+		if err != nil {
+			return nil, err
+		}
+		t := time.Now()
+
+		for _, def := range block.Defs {
+			offset := def.Address - block.Address
+			value := parseSMAValue(def, registers[offset:offset+def.Type.size()])
+			if value != nil {
+				values[def.ID] = sunny.TimedValue{
+					Value: value,
+					Time:  t,
+				}
+			}
+		}
+	}
+	return values, nil
+}
+
+// ensure Device implements sunny.ValueReader
+var _ sunny.ValueReader = (*Device)(nil)
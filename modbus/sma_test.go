@@ -0,0 +1,160 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package modbus
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// startServer with the given registers and returns the address
+func startServer(t *testing.T, registers *RegisterMap) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	server := &Server{Handler: registers}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+	return listener.Addr().String()
+}
+
+// smaTestRegisters with all known registers set to NaN
+func smaTestRegisters() *RegisterMap {
+	registers := NewRegisterMap()
+	for _, block := range smaRegisterBlocksAll {
+		data := make([]uint16, block.Quantity)
+		for i := range data {
+			data[i] = 0xFFFF
+		}
+		for _, def := range block.Defs {
+			if def.Type == typeS32 {
+				data[def.Address-block.Address] = 0x8000
+				data[def.Address-block.Address+1] = 0x0000
+			}
+		}
+		registers.SetHoldingRegisters(DefaultUnit, block.Address, data)
+	}
+	registers.SetHoldingRegisters(DefaultUnit, smaRegisterSerial, []uint16{0x4996, 0x02D2})
+	return registers
+}
+
+func TestClient(t *testing.T) {
+	ass := assert.New(t)
+
+	registers := NewRegisterMap()
+	registers.SetInputRegisters(1, 0, []uint16{1, 2, 3})
+	client := NewClient(startServer(t, registers))
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	values, err := client.ReadInputRegisters(ctx, 1, 1, 2)
+	ass.NoError(err)
+	ass.Equal([]uint16{2, 3}, values)
+
+	_, err = client.ReadInputRegisters(ctx, 1, 2, 2)
+	ass.Equal(ErrIllegalDataAddress, err)
+
+	_, err = client.ReadInputRegisters(ctx, 1, 0, 200)
+	ass.Error(err)
+
+	// connection is reused after exception
+	values, err = client.ReadInputRegisters(ctx, 1, 0, 1)
+	ass.NoError(err)
+	ass.Equal([]uint16{1}, values)
+
+	ass.Equal("localhost:502", NewClient("localhost").Address())
+}
+
+func TestSmaRegisterBlocks(t *testing.T) {
+	ass := assert.New(t)
+
+	blocks := smaRegisterBlocks([]smaRegisterDef{
+		{30777, typeS32, sunny.ActivePowerPlusL1, 0},
+		{30513, typeU64, sunny.ActiveEnergyPlus, 3600},
+		{30775, typeS32, sunny.ActivePowerPlus, 0},
+		{30953, typeS32, sunny.DeviceTemperature, 0.1},
+	})
+	ass.Len(blocks, 3)
+	ass.Equal(uint16(30513), blocks[0].Address)
+	ass.Equal(uint16(4), blocks[0].Quantity)
+	ass.Equal(uint16(30775), blocks[1].Address)
+	ass.Equal(uint16(4), blocks[1].Quantity)
+	ass.Len(blocks[1].Defs, 2)
+	ass.Equal(uint16(30953), blocks[2].Address)
+
+	for _, block := range smaRegisterBlocksAll {
+		ass.LessOrEqual(block.Quantity, uint16(maxReadQuantity))
+	}
+}
+
+func TestDevice(t *testing.T) {
+	ass := assert.New(t)
+
+	registers := smaTestRegisters()
+	registers.SetHoldingRegisters(DefaultUnit, 30201, []uint16{0, 307})
+	registers.SetHoldingRegisters(DefaultUnit, 30513, []uint16{0, 0, 0, 12345})
+	registers.SetHoldingRegisters(DefaultUnit, 30775, []uint16{0, 4200})
+	registers.SetHoldingRegisters(DefaultUnit, 30777, []uint16{0xFFFF, 0xFFFE})
+	registers.SetHoldingRegisters(DefaultUnit, 30783, []uint16{0, 23012})
+	registers.SetHoldingRegisters(DefaultUnit, 30953, []uint16{0xFFFF, 0xFFF6})
+
+	device, err := NewDevice(startServer(t, registers), DefaultUnit)
+	ass.NoError(err)
+	defer device.Close()
+	ass.Equal(uint32(1234567890), device.SerialNumber())
+	ass.False(device.IsEnergyMeter())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	values, err := device.GetValuesCtx(ctx)
+	ass.NoError(err)
+	ass.Equal(map[sunny.ValueID]interface{}{
+		sunny.DeviceStatus:      uint32(307),
+		sunny.ActiveEnergyPlus:  float64(12345 * 3600),
+		sunny.ActivePowerPlus:   uint32(4200),
+		sunny.ActivePowerPlusL1: uint32(0),
+		sunny.VoltageL1:         230.12,
+		sunny.DeviceTemperature: -1.,
+	}, values)
+
+	value, err := device.GetValueCtx(ctx, sunny.ActivePowerPlus)
+	ass.NoError(err)
+	ass.Equal(uint32(4200), value)
+
+	value, err = device.GetValueCtx(ctx, sunny.VoltageL2)
+	ass.NoError(err)
+	ass.Nil(value)
+
+	value, err = device.GetValueCtx(ctx, sunny.BatteryCharge)
+	ass.True(errors.Is(err, sunny.ErrNotSupported))
+	ass.Nil(value)
+
+	_, err = NewDevice(device.Address(), 42)
+	ass.Error(err)
+}
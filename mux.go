@@ -0,0 +1,129 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"sync"
+
+	"gitlab.com/bboehmke/sunny/proto"
+	"gitlab.com/bboehmke/sunny/proto/net2"
+)
+
+// requestMux routes the received packets of a device to the waiting requests
+// which allows multiple requests in flight at the same time.
+// Responses are matched by packet ID only. The job number is constant for
+// most requests and inverters are not known to echo it reliably.
+type requestMux struct {
+	// identity of the local system (destination of responses)
+	identity net2.DeviceId
+
+	mutex   sync.Mutex
+	pending map[uint16]chan *net2.DeviceData
+	meters  map[chan *net2.EnergyMeterPacket]bool
+}
+
+// newRequestMux creates a mux for responses addressed to identity
+func newRequestMux(identity net2.DeviceId) *requestMux {
+	return &requestMux{
+		identity: identity,
+		pending:  make(map[uint16]chan *net2.DeviceData),
+		meters:   make(map[chan *net2.EnergyMeterPacket]bool),
+	}
+}
+
+// run dispatches packets from receiver until done is closed
+func (m *requestMux) run(receiver chan *proto.Packet, done chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case packet := <-receiver:
+			m.dispatch(packet)
+		}
+	}
+}
+
+// dispatch packet to the matching request or energy meter subscribers
+func (m *requestMux) dispatch(packet *proto.Packet) {
+	entry := packet.GetEntry(proto.SmaNet2PacketEntryTag)
+	if entry == nil {
+		return
+	}
+
+	switch content := entry.(*proto.SmaNet2PacketEntry).Content.(type) {
+	case *net2.DeviceData:
+		// response to request of another system
+		if content.Destination != m.identity && content.Destination.SerialNumber != 0xFFFFFFFF {
+			return
+		}
+
+		m.mutex.Lock()
+		ch, ok := m.pending[content.PacketID]
+		m.mutex.Unlock()
+		if !ok {
+			return // no request waiting (e.g. late response after resend)
+		}
+
+		select {
+		case ch <- content:
+		default:
+			// response already received -> drop duplicate
+		}
+
+	case *net2.EnergyMeterPacket:
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		for ch := range m.meters {
+			select {
+			case ch <- content:
+			default:
+			}
+		}
+	}
+}
+
+// register request and return channel for the response
+func (m *requestMux) register(data *net2.DeviceData) chan *net2.DeviceData {
+	ch := make(chan *net2.DeviceData, 1)
+
+	m.mutex.Lock()
+	m.pending[data.PacketID] = ch
+	m.mutex.Unlock()
+	return ch
+}
+
+// unregister request
+func (m *requestMux) unregister(data *net2.DeviceData) {
+	m.mutex.Lock()
+	delete(m.pending, data.PacketID)
+	m.mutex.Unlock()
+}
+
+// subscribeMeter returns a channel for the next energy meter packets
+func (m *requestMux) subscribeMeter() chan *net2.EnergyMeterPacket {
+	ch := make(chan *net2.EnergyMeterPacket, 1)
+
+	m.mutex.Lock()
+	m.meters[ch] = true
+	m.mutex.Unlock()
+	return ch
+}
+
+// unsubscribeMeter channel of subscribeMeter
+func (m *requestMux) unsubscribeMeter(ch chan *net2.EnergyMeterPacket) {
+	m.mutex.Lock()
+	delete(m.meters, ch)
+	m.mutex.Unlock()
+}
@@ -0,0 +1,259 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny/proto"
+	"gitlab.com/bboehmke/sunny/proto/net2"
+)
+
+var (
+	muxIdentity = net2.DeviceId{SusyID: identitySusyID, SerialNumber: 1234567890}
+	muxInverter = net2.DeviceId{SusyID: 0x1234, SerialNumber: 2000000001}
+)
+
+// muxResponse to request addressed to destination as received from the wire
+func muxResponse(t *testing.T, request *net2.DeviceData, destination net2.DeviceId) *proto.Packet {
+	var pack proto.Packet
+	pack.AddEntry(&proto.GroupPacketEntry{Group: 0x00000001})
+	pack.AddEntry(&proto.SmaNet2PacketEntry{Content: &net2.DeviceData{
+		Control:     request.Control,
+		Destination: destination,
+		JobNumber:   request.JobNumber,
+		Source:      muxInverter,
+		PacketID:    request.PacketID,
+		Command:     request.Command | 0x01,
+		Object:      request.Object,
+		Parameters:  []uint32{0, 0},
+	}})
+
+	var received proto.Packet
+	err := received.Read(pack.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &received
+}
+
+// muxRequest registered at mux
+func muxRequest(mux *requestMux, object uint16) (*net2.DeviceData, chan *net2.DeviceData) {
+	request := net2.NewDeviceData(0xa0)
+	request.Object = object
+	return request, mux.register(request)
+}
+
+func TestRequestMux_Concurrent(t *testing.T) {
+	ass := assert.New(t)
+	mux := newRequestMux(muxIdentity)
+
+	// many requests in flight -> responses in reverse order
+	var requests []*net2.DeviceData
+	var responses []chan *net2.DeviceData
+	for i := 0; i < 10; i++ {
+		request, response := muxRequest(mux, uint16(0x5100+i))
+		requests = append(requests, request)
+		responses = append(responses, response)
+	}
+	for i := len(requests) - 1; i >= 0; i-- {
+		mux.dispatch(muxResponse(t, requests[i], muxIdentity))
+	}
+
+	for i, response := range responses {
+		if ass.Len(response, 1) {
+			data := <-response
+			ass.Equal(requests[i].PacketID, data.PacketID)
+			ass.Equal(requests[i].Object, data.Object)
+		}
+		mux.unregister(requests[i])
+	}
+	ass.Empty(mux.pending)
+}
+
+func TestRequestMux_Run(t *testing.T) {
+	ass := assert.New(t)
+	mux := newRequestMux(muxIdentity)
+
+	receiver := make(chan *proto.Packet, 16)
+	done := make(chan struct{})
+	defer close(done)
+	go mux.run(receiver, done)
+
+	// concurrent requests receive their own response
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		request, response := muxRequest(mux, uint16(0x5100+i))
+		packet := muxResponse(t, request, muxIdentity)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer mux.unregister(request)
+			receiver <- packet
+
+			select {
+			case data := <-response:
+				ass.Equal(request.Object, data.Object)
+			case <-time.After(time.Second):
+				ass.Fail("no response")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRequestMux_Dropped(t *testing.T) {
+	ass := assert.New(t)
+	mux := newRequestMux(muxIdentity)
+
+	request, response := muxRequest(mux, 0x5100)
+
+	// response to request of another system
+	other := muxIdentity
+	other.SerialNumber++
+	mux.dispatch(muxResponse(t, request, other))
+	ass.Len(response, 0)
+
+	// unknown packet ID
+	unknown := *request
+	unknown.PacketID = (request.PacketID + 1) & 0x7FFF
+	mux.dispatch(muxResponse(t, &unknown, muxIdentity))
+	ass.Len(response, 0)
+
+	// response and duplicate (e.g. after resend)
+	mux.dispatch(muxResponse(t, request, muxIdentity))
+	mux.dispatch(muxResponse(t, request, muxIdentity))
+	ass.Len(response, 1)
+	<-response
+	ass.Len(response, 0)
+
+	// late response after request is done
+	mux.unregister(request)
+	mux.dispatch(muxResponse(t, request, muxIdentity))
+	ass.Len(response, 0)
+	ass.Empty(mux.pending)
+}
+
+func TestRequestMux_Broadcast(t *testing.T) {
+	ass := assert.New(t)
+	mux := newRequestMux(muxIdentity)
+
+	// responses to broadcasts (e.g. ping of unknown device)
+	request, response := muxRequest(mux, 0x0000)
+	mux.dispatch(muxResponse(t, request, net2.DeviceId{SusyID: 0xFFFF, SerialNumber: 0xFFFFFFFF}))
+	ass.Len(response, 1)
+	mux.unregister(request)
+}
+
+func TestRequestMux_JobNumber(t *testing.T) {
+	ass := assert.New(t)
+	mux := newRequestMux(muxIdentity)
+
+	// job number is not part of the routing
+	request, response := muxRequest(mux, 0x5100)
+	request.JobNumber = 0x01
+	reply := *request
+	reply.JobNumber = 0x00
+	mux.dispatch(muxResponse(t, &reply, muxIdentity))
+	ass.Len(response, 1)
+	mux.unregister(request)
+}
+
+func TestRequestMux_Meter(t *testing.T) {
+	ass := assert.New(t)
+	mux := newRequestMux(muxIdentity)
+
+	meter := net2Packet(&proto.SmaNet2PacketEntry{
+		Content: &net2.EnergyMeterPacket{Id: muxInverter},
+	})
+
+	a := mux.subscribeMeter()
+	b := mux.subscribeMeter()
+	mux.dispatch(meter)
+	ass.Len(a, 1)
+	ass.Len(b, 1)
+
+	// subscriber busy -> dropped
+	mux.dispatch(meter)
+	ass.Len(a, 1)
+
+	mux.unsubscribeMeter(a)
+	mux.unsubscribeMeter(b)
+	ass.Empty(mux.meters)
+}
+
+// discardTransport drops all sent packets
+type discardTransport struct {
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (t *discardTransport) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	<-t.closed
+	return 0, nil, net.ErrClosed
+}
+
+func (t *discardTransport) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	return len(b), nil
+}
+
+func (t *discardTransport) Close() error {
+	t.once.Do(func() {
+		close(t.closed)
+	})
+	return nil
+}
+
+func TestRequestMux_Cancel(t *testing.T) {
+	ass := assert.New(t)
+
+	conn := &Connection{
+		identity:         muxIdentity,
+		policy:           DefaultPolicy,
+		receiverChannels: make(map[string][]chan *proto.Packet),
+		socket:           &discardTransport{closed: make(chan struct{})},
+	}
+	go conn.listenLoop()
+	defer conn.close()
+
+	device, err := conn.newDevice("127.0.0.1", "0000")
+	if !ass.NoError(err) {
+		return
+	}
+	defer device.Close()
+
+	// canceled and timed out requests are removed from the mux
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*20, cancel)
+	_, err = device.sendDeviceDataResponse(ctx, net2.NewDeviceData(0xa0))
+	ass.Equal(context.Canceled, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	_, err = device.sendDeviceDataResponse(ctx, net2.NewDeviceData(0xa0))
+	ass.True(errors.Is(err, ErrTimeout))
+
+	device.mux.mutex.Lock()
+	ass.Empty(device.mux.pending)
+	device.mux.mutex.Unlock()
+}
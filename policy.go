@@ -0,0 +1,117 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Policy for timeouts and retries of device requests
+// Fields with zero value are replaced by the ones of DefaultPolicy.
+type Policy struct {
+	// Timeout of a whole operation (e.g. GetValues) if the context has no deadline
+	Timeout time.Duration
+	// ResendInterval of a request without response
+	ResendInterval time.Duration
+	// MaxResendInterval limits the resend interval increased by Backoff
+	MaxResendInterval time.Duration
+	// Backoff factor of the resend interval after every resend (1 = constant)
+	Backoff float64
+	// Jitter of the resend interval as fraction (e.g. 0.2 = ±20%, max 0.9)
+	Jitter float64
+	// MaxRetries of a single request (0 = resend until timeout)
+	MaxRetries int
+	// LoginRetries amount of login attempts
+	LoginRetries int
+}
+
+// DefaultPolicy used for connections without an explicit policy
+var DefaultPolicy = Policy{
+	Timeout:           time.Second * 3,
+	ResendInterval:    time.Millisecond * 500,
+	MaxResendInterval: time.Second * 5,
+	Backoff:           1,
+	Jitter:            0,
+	MaxRetries:        0,
+	LoginRetries:      3,
+}
+
+// maxJitter keeps the resend interval positive
+const maxJitter = 0.9
+
+// withDefaults returns policy with zero values replaced by DefaultPolicy
+func (p Policy) withDefaults() Policy {
+	if p.Timeout <= 0 {
+		p.Timeout = DefaultPolicy.Timeout
+	}
+	if p.ResendInterval <= 0 {
+		p.ResendInterval = DefaultPolicy.ResendInterval
+	}
+	if p.MaxResendInterval <= 0 {
+		p.MaxResendInterval = DefaultPolicy.MaxResendInterval
+	}
+	if p.Backoff < 1 {
+		p.Backoff = 1
+	}
+	if p.Jitter < 0 {
+		p.Jitter = 0
+	}
+	if p.Jitter > maxJitter {
+		p.Jitter = maxJitter
+	}
+	if p.LoginRetries <= 0 {
+		p.LoginRetries = DefaultPolicy.LoginRetries
+	}
+	return p
+}
+
+// withTimeout adds the policy timeout to ctx if it has no deadline
+func (p Policy) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, p.Timeout)
+}
+
+// resendInterval before the given retry (starting with 0)
+func (p Policy) resendInterval(retry int) time.Duration {
+	interval := float64(p.ResendInterval) * math.Pow(p.Backoff, float64(retry))
+	if interval > float64(p.MaxResendInterval) {
+		interval = float64(p.MaxResendInterval)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, maxJitter)
+		interval += interval * jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(interval)
+}
+
+// policyKey of policy in context
+type policyKey struct{}
+
+// WithPolicy returns a context that overrides the policy of a device for all
+// operations called with this context
+func WithPolicy(ctx context.Context, policy Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, policy)
+}
+
+// policyFromContext returns the policy of WithPolicy
+func policyFromContext(ctx context.Context) (Policy, bool) {
+	policy, ok := ctx.Value(policyKey{}).(Policy)
+	return policy, ok
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_withDefaults(t *testing.T) {
+	ass := assert.New(t)
+
+	ass.Equal(DefaultPolicy, Policy{}.withDefaults())
+
+	policy := Policy{
+		Timeout: time.Second * 10,
+		Backoff: 2,
+	}.withDefaults()
+	ass.Equal(time.Second*10, policy.Timeout)
+	ass.Equal(DefaultPolicy.ResendInterval, policy.ResendInterval)
+	ass.Equal(2.0, policy.Backoff)
+}
+
+func TestPolicy_resendInterval(t *testing.T) {
+	ass := assert.New(t)
+
+	policy := Policy{
+		ResendInterval:    time.Millisecond * 100,
+		MaxResendInterval: time.Millisecond * 500,
+		Backoff:           2,
+	}.withDefaults()
+	ass.Equal(time.Millisecond*100, policy.resendInterval(0))
+	ass.Equal(time.Millisecond*200, policy.resendInterval(1))
+	ass.Equal(time.Millisecond*400, policy.resendInterval(2))
+	ass.Equal(time.Millisecond*500, policy.resendInterval(3))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		interval := policy.resendInterval(0)
+		ass.True(interval >= time.Millisecond*50)
+		ass.True(interval <= time.Millisecond*150)
+	}
+
+	// jitter is limited -> interval stays positive
+	policy = Policy{
+		ResendInterval: time.Millisecond * 100,
+		Jitter:         2,
+	}.withDefaults()
+	ass.Equal(maxJitter, policy.Jitter)
+	policy.Jitter = 2
+	for i := 0; i < 100; i++ {
+		interval := policy.resendInterval(0)
+		ass.True(interval >= time.Millisecond*10)
+		ass.True(interval <= time.Millisecond*190)
+	}
+}
+
+func TestPolicy_withTimeout(t *testing.T) {
+	ass := assert.New(t)
+
+	policy := Policy{Timeout: time.Second}.withDefaults()
+	ctx, cancel := policy.withTimeout(context.Background())
+	deadline, ok := ctx.Deadline()
+	cancel()
+	ass.True(ok)
+	ass.WithinDuration(time.Now().Add(time.Second), deadline, time.Millisecond*100)
+
+	// keep existing deadline
+	parent, parentCancel := context.WithTimeout(context.Background(), time.Minute)
+	defer parentCancel()
+	ctx, cancel = policy.withTimeout(parent)
+	deadline, _ = ctx.Deadline()
+	cancel()
+	ass.WithinDuration(time.Now().Add(time.Minute), deadline, time.Millisecond*100)
+}
+
+func TestDevice_Policy(t *testing.T) {
+	ass := assert.New(t)
+	newSimulatedInverter(t)
+
+	device, err := NewUnicastDevice("127.0.0.1", "0000")
+	if !ass.NoError(err) {
+		return
+	}
+	defer device.Close()
+	ass.Equal(DefaultPolicy, device.Policy())
+
+	device.SetPolicy(Policy{LoginRetries: 1})
+	ass.Equal(1, device.Policy().LoginRetries)
+	ass.Equal(DefaultPolicy.Timeout, device.Policy().Timeout)
+
+	// override with context
+	ctx := WithPolicy(context.Background(), Policy{MaxRetries: 2})
+	ass.Equal(2, device.policyFor(ctx).MaxRetries)
+	ass.Equal(DefaultPolicy.LoginRetries, device.policyFor(ctx).LoginRetries)
+
+	value, err := device.GetValueCtx(ctx, DeviceName)
+	ass.NoError(err)
+	ass.Equal("Simulated Inverter", value)
+}
@@ -114,7 +114,7 @@ func (p *Packet) Read(data []byte) error {
 		length := binary.BigEndian.Uint16(buffer.Next(2))
 		tag := binary.BigEndian.Uint16(buffer.Next(2)) // including version
 
-		if length == 0 {
+		if length == 0 && tag == 0 {
 			// last packet
 			break
 		}
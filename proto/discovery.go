@@ -28,6 +28,30 @@ func NewDiscoveryRequest() *Packet {
 	return &pack
 }
 
+// NewDiscoveryResponse creates a discovery response that advertises ip
+// (structure based on the responses of SMA inverters)
+func NewDiscoveryResponse(ip net.IP) *Packet {
+	var pack Packet
+	pack.AddEntry(&GroupPacketEntry{
+		Group: 0x00000001,
+	})
+	pack.AddEntry(&UnknownPacketEntry{T: 0x0000, Data: []byte{0x00, 0x01}})
+	pack.AddEntry(&UnknownPacketEntry{T: 0x0010, Data: []byte{0x00, 0x01, 0x00, 0x03}})
+	pack.AddEntry(&UnknownPacketEntry{T: DiscoveryRequestPacketEntryTag, Data: []byte{0x00, 0x00, 0x00, 0x01}})
+	pack.AddEntry(&DiscoveryIPPacketEntry{IP: ip.To4()})
+	pack.AddEntry(&UnknownPacketEntry{T: 0x0040, Data: []byte{0x00, 0x00, 0x00, 0x00}})
+	pack.AddEntry(&UnknownPacketEntry{T: 0x0070, Data: []byte{0xef, 0x0c}})
+	pack.AddEntry(&UnknownPacketEntry{T: 0x0080, Data: []byte{0x00}})
+	return &pack
+}
+
+// IsDiscoveryRequest returns true if the packet is a discovery request
+// (responses contain also a request entry but with an IP entry)
+func (p *Packet) IsDiscoveryRequest() bool {
+	return p.GetEntry(DiscoveryRequestPacketEntryTag) != nil &&
+		p.GetEntry(DiscoveryIPPacketEntryTag) == nil
+}
+
 // DiscoveryRequestPacketEntryTag identifier for discovery request entries
 const DiscoveryRequestPacketEntryTag uint16 = 0x0020
 
@@ -74,3 +98,17 @@ func (e *DiscoveryIPPacketEntry) Read(data []byte) (PacketEntry, error) {
 		IP: data,
 	}, nil
 }
+
+// DiscoveryIP returns the advertised IP address of a discovery response
+// or nil if the packet is no discovery response
+func (p *Packet) DiscoveryIP() net.IP {
+	entry, ok := p.GetEntry(DiscoveryIPPacketEntryTag).(*DiscoveryIPPacketEntry)
+	if !ok {
+		return nil
+	}
+	ip := entry.IP.To4()
+	if ip == nil || ip.IsUnspecified() {
+		return nil
+	}
+	return ip
+}
@@ -76,3 +76,51 @@ func TestDiscoveryIPPacketEntry_Read(t *testing.T) {
 	ass.NotNil(entry)
 	ass.Equal(net.IP([]byte{0x01, 0x02, 0x03, 0x04}), entry.(*DiscoveryIPPacketEntry).IP)
 }
+
+func TestPacket_DiscoveryIP(t *testing.T) {
+	ass := assert.New(t)
+
+	// discovery response of an inverter
+	var packet Packet
+	ass.NoError(packet.Read([]byte{
+		0x53, 0x4d, 0x41, 0x00, 0x00, 0x04, 0x02, 0xa0,
+		0x00, 0x00, 0x00, 0x01, 0x00, 0x02, 0x00, 0x00,
+		0x00, 0x01, 0x00, 0x04, 0x00, 0x10, 0x00, 0x01,
+		0x00, 0x03, 0x00, 0x04, 0x00, 0x20, 0x00, 0x00,
+		0x00, 0x01, 0x00, 0x04, 0x00, 0x30, 0xc0, 0xa8,
+		0xb2, 0x14, 0x00, 0x02, 0x00, 0x70, 0xef, 0x00,
+		0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00,
+	}))
+	ass.Equal(net.IPv4(192, 168, 178, 20).To4(), packet.DiscoveryIP())
+
+	// no IP entry
+	ass.Nil(NewDiscoveryRequest().DiscoveryIP())
+
+	// invalid IP entry
+	packet = Packet{}
+	packet.AddEntry(&DiscoveryIPPacketEntry{IP: []byte{0, 0, 0, 0}})
+	ass.Nil(packet.DiscoveryIP())
+}
+
+func TestNewDiscoveryResponse(t *testing.T) {
+	ass := assert.New(t)
+
+	response := NewDiscoveryResponse(net.IPv4(192, 168, 178, 20))
+	ass.False(response.IsDiscoveryRequest())
+
+	var packet Packet
+	ass.NoError(packet.Read(response.Bytes()))
+	ass.Equal(net.IPv4(192, 168, 178, 20).To4(), packet.DiscoveryIP())
+	ass.False(packet.IsDiscoveryRequest())
+}
+
+func TestPacket_IsDiscoveryRequest(t *testing.T) {
+	ass := assert.New(t)
+
+	var packet Packet
+	ass.NoError(packet.Read(NewDiscoveryRequest().Bytes()))
+	ass.True(packet.IsDiscoveryRequest())
+
+	ass.False(new(Packet).IsDiscoveryRequest())
+}
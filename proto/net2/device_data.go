@@ -18,6 +18,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"strings"
+	"sync"
 	"sync/atomic"
 )
 
@@ -222,21 +223,23 @@ var packetIDCounter uint32
 
 // more or less unique ID of the current system
 var systemID *DeviceId
+var systemIDOnce sync.Once
 
 // NewDeviceData creates a device data request
+// The source is set to LocalDeviceId and can be replaced before sending.
 func NewDeviceData(control uint8) *DeviceData {
 	pkgId := atomic.AddUint32(&packetIDCounter, 1)
 
 	// initialize system id on first call
-	if systemID == nil {
+	systemIDOnce.Do(func() {
 		systemID = LocalDeviceId()
-	}
+	})
 
 	return &DeviceData{
 		Control: control,
 		Source:  *systemID,
-		// count up only last byte
-		PacketID: uint16(pkgId & 0xFF),
+		// highest bit is used as flag -> 15 bit counter
+		PacketID: uint16(pkgId & 0x7FFF),
 	}
 }
 
@@ -0,0 +1,39 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import "context"
+
+// ValueReader provides the values of a device independent of the transport
+// (Speedwire Device or Modbus TCP)
+type ValueReader interface {
+	// SerialNumber of the device
+	SerialNumber() uint32
+	// IsEnergyMeter returns true if device is an energy meter
+	IsEnergyMeter() bool
+
+	// GetValueCtx returns a single value or nil if value does not exist
+	GetValueCtx(ctx context.Context, id ValueID) (interface{}, error)
+	// GetValuesCtx returns all values of the device
+	GetValuesCtx(ctx context.Context) (map[ValueID]interface{}, error)
+	// GetTimedValuesCtx returns all values of the device with timestamp
+	GetTimedValuesCtx(ctx context.Context) (map[ValueID]TimedValue, error)
+
+	// Close releases all resources of the device
+	Close()
+}
+
+// ensure Device implements ValueReader
+var _ ValueReader = (*Device)(nil)
@@ -0,0 +1,121 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// transport of a connection (implemented by *net.UDPConn)
+type transport interface {
+	ReadFromUDP(b []byte) (int, *net.UDPAddr, error)
+	WriteToUDP(b []byte, addr *net.UDPAddr) (int, error)
+	Close() error
+}
+
+// PacketDirection of a recorded packet
+type PacketDirection string
+
+// Directions of recorded packets
+const (
+	PacketSent     PacketDirection = "send"
+	PacketReceived PacketDirection = "recv"
+)
+
+// PacketRecord of a sent or received Speedwire packet
+type PacketRecord struct {
+	Time      time.Time       `json:"time"`
+	Direction PacketDirection `json:"direction"`
+	// Peer the packet was sent to or received from
+	Peer string `json:"peer"`
+	// Data of packet hex encoded
+	Data string `json:"data"`
+}
+
+// Recorder writes packets as JSON lines (one PacketRecord per line)
+type Recorder struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewRecorder writing to w
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// Record packet data sent to or received from peer
+func (r *Recorder) Record(direction PacketDirection, peer *net.UDPAddr, data []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.encoder.Encode(PacketRecord{
+		Time:      time.Now(),
+		Direction: direction,
+		Peer:      peer.String(),
+		Data:      hex.EncodeToString(data),
+	})
+}
+
+// ReadRecords from JSON lines written by a Recorder
+func ReadRecords(r io.Reader) ([]PacketRecord, error) {
+	var records []PacketRecord
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record PacketRecord
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, fmt.Errorf("invalid record in line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// SetRecorder for all packets sent and received by this connection
+// (nil disables recording)
+func (c *Connection) SetRecorder(recorder *Recorder) {
+	c.settingsMutex.Lock()
+	c.recorder = recorder
+	c.settingsMutex.Unlock()
+}
+
+// record packet if a recorder is set
+func (c *Connection) record(direction PacketDirection, peer *net.UDPAddr, data []byte) {
+	c.settingsMutex.RLock()
+	recorder := c.recorder
+	c.settingsMutex.RUnlock()
+
+	if recorder == nil {
+		return
+	}
+	err := recorder.Record(direction, peer, data)
+	if err != nil {
+		Log.Printf("failed to record packet: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny/proto/net2"
+)
+
+func TestRecorder(t *testing.T) {
+	ass := assert.New(t)
+
+	var buf bytes.Buffer
+	recorder := NewRecorder(&buf)
+	peer := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 9522}
+	ass.NoError(recorder.Record(PacketSent, peer, []byte{0x53, 0x4d, 0x41, 0x00}))
+	ass.NoError(recorder.Record(PacketReceived, peer, []byte{0x01, 0x02}))
+
+	records, err := ReadRecords(&buf)
+	ass.NoError(err)
+	if ass.Len(records, 2) {
+		ass.Equal(PacketSent, records[0].Direction)
+		ass.Equal("192.168.1.10:9522", records[0].Peer)
+		ass.Equal("534d4100", records[0].Data)
+		ass.Equal(PacketReceived, records[1].Direction)
+		ass.Equal("0102", records[1].Data)
+	}
+
+	_, err = ReadRecords(bytes.NewBufferString("{invalid\n"))
+	ass.Error(err)
+}
+
+func TestReplayConnection(t *testing.T) {
+	ass := assert.New(t)
+	sim := newSimulatedInverter(t)
+
+	// record communication with simulated inverter
+	var buf bytes.Buffer
+	conn, err := newUnicastConnection(*net2.LocalDeviceId())
+	if !ass.NoError(err) {
+		return
+	}
+	conn.SetRecorder(NewRecorder(&buf))
+
+	device, err := conn.NewDevice("127.0.0.1", "0000")
+	if !ass.NoError(err) {
+		conn.close()
+		return
+	}
+	recorded, err := device.Info(context.Background())
+	ass.NoError(err)
+	device.Close()
+	conn.close()
+	ass.Equal(sim.id.SerialNumber, recorded.SerialNumber)
+
+	// replay without simulated inverter
+	requests := sim.requestCount(0x5800)
+	replay, err := NewReplayConnection(&buf)
+	if !ass.NoError(err) {
+		return
+	}
+	defer replay.close()
+	ass.Equal(conn.Identity(), replay.Identity())
+
+	device, err = replay.NewDevice("127.0.0.1", "0000")
+	if !ass.NoError(err) {
+		return
+	}
+	defer device.Close()
+
+	replayed, err := device.Info(context.Background())
+	ass.NoError(err)
+	ass.Equal(recorded, replayed)
+	ass.Equal(requests, sim.requestCount(0x5800))
+}
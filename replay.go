@@ -0,0 +1,211 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"gitlab.com/bboehmke/sunny/proto"
+	"gitlab.com/bboehmke/sunny/proto/net2"
+)
+
+// replayPacket of a recording
+type replayPacket struct {
+	direction PacketDirection
+	peer      *net.UDPAddr
+	data      []byte
+}
+
+// replayTransport feeds recorded packets to a connection
+// Received packets are released after the sent packet that preceded them in
+// the recording. Packet IDs are mapped to the ones of the replayed requests.
+type replayTransport struct {
+	mutex   sync.Mutex
+	packets []replayPacket
+	// packet IDs of recorded requests mapped to the replayed requests
+	packetIDs map[uint16]uint16
+
+	received  chan replayPacket
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newReplayTransport for the given records
+func newReplayTransport(records []PacketRecord) (*replayTransport, error) {
+	t := &replayTransport{
+		packets:   make([]replayPacket, 0, len(records)),
+		packetIDs: make(map[uint16]uint16),
+		received:  make(chan replayPacket, len(records)),
+		closed:    make(chan struct{}),
+	}
+
+	for i, record := range records {
+		peer, err := net.ResolveUDPAddr("udp", record.Peer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer of record %d: %w", i, err)
+		}
+		data, err := hex.DecodeString(record.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data of record %d: %w", i, err)
+		}
+		t.packets = append(t.packets, replayPacket{
+			direction: record.Direction,
+			peer:      peer,
+			data:      data,
+		})
+	}
+
+	// packets received before the first request
+	t.releaseReceived()
+	return t, nil
+}
+
+// releaseReceived packets until the next sent packet (mutex must be locked)
+func (t *replayTransport) releaseReceived() {
+	for len(t.packets) > 0 && t.packets[0].direction != PacketSent {
+		packet := t.packets[0]
+		t.packets = t.packets[1:]
+
+		// response to a replayed request -> use packet ID of this request
+		if offset := deviceDataOffset(packet.data); offset >= 0 {
+			raw := binary.LittleEndian.Uint16(packet.data[offset+22:])
+			if id, ok := t.packetIDs[raw&0x7FFF]; ok {
+				binary.LittleEndian.PutUint16(packet.data[offset+22:], id|raw&0x8000)
+			}
+		}
+		t.received <- packet
+	}
+}
+
+// ReadFromUDP returns the next released packet
+func (t *replayTransport) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	select {
+	case <-t.closed:
+		return 0, nil, net.ErrClosed
+	case packet := <-t.received:
+		return copy(b, packet.data), packet.peer, nil
+	}
+}
+
+// WriteToUDP consumes the next sent packet of the recording and releases the
+// packets received after it
+func (t *replayTransport) WriteToUDP(b []byte, addr *net.UDPAddr) (int, error) {
+	select {
+	case <-t.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if len(t.packets) == 0 {
+		Log.Printf("replay - no recorded packet left for %s", addr)
+		return len(b), nil
+	}
+
+	recorded := t.packets[0]
+	t.packets = t.packets[1:]
+	if !recorded.peer.IP.Equal(addr.IP) {
+		Log.Printf("replay - packet sent to %s but recorded for %s", addr, recorded.peer)
+	}
+
+	recordedOffset := deviceDataOffset(recorded.data)
+	offset := deviceDataOffset(b)
+	if recordedOffset >= 0 && offset >= 0 {
+		recordedID := binary.LittleEndian.Uint16(recorded.data[recordedOffset+22:]) & 0x7FFF
+		t.packetIDs[recordedID] = binary.LittleEndian.Uint16(b[offset+22:]) & 0x7FFF
+	}
+
+	t.releaseReceived()
+	return len(b), nil
+}
+
+// Close transport
+func (t *replayTransport) Close() error {
+	t.closeOnce.Do(func() {
+		close(t.closed)
+	})
+	return nil
+}
+
+// deviceDataOffset returns the offset of net2.DeviceData in a raw packet or
+// -1 if the packet contains no device data
+func deviceDataOffset(data []byte) int {
+	offset := 4 // packet header
+	for offset+4 <= len(data) {
+		length := int(binary.BigEndian.Uint16(data[offset:]))
+		tag := binary.BigEndian.Uint16(data[offset+2:])
+		if length == 0 && tag == 0 {
+			break
+		}
+
+		content := offset + 4
+		if tag == proto.SmaNet2PacketEntryTag && content+2+28 <= len(data) &&
+			binary.BigEndian.Uint16(data[content:]) == net2.DeviceDataProtocolID {
+			return content + 2
+		}
+		offset = content + length
+	}
+	return -1
+}
+
+// NewReplayConnection creates a connection that replays packets of a
+// recording (see Recorder) instead of using the network. Devices of this
+// connection behave like the recorded ones which allows to re-run the device
+// logic offline. The identity of the connection is taken from the first
+// recorded request.
+func NewReplayConnection(r io.Reader) (*Connection, error) {
+	records, err := ReadRecords(r)
+	if err != nil {
+		return nil, err
+	}
+
+	socket, err := newReplayTransport(records)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := Connection{
+		identity:         *net2.LocalDeviceId(),
+		policy:           DefaultPolicy,
+		capabilities:     newMemoryCapabilityCache(),
+		receiverChannels: make(map[string][]chan *proto.Packet),
+		socket:           socket,
+	}
+	conn.address, err = net.ResolveUDPAddr("udp", listenAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	// use identity of recording -> responses are addressed to this connection
+	for _, packet := range socket.packets {
+		if offset := deviceDataOffset(packet.data); packet.direction == PacketSent && offset >= 0 {
+			err = conn.identity.Read(packet.data[offset+10:], binary.LittleEndian)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	go conn.listenLoop()
+	return &conn, nil
+}
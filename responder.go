@@ -0,0 +1,100 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"net"
+	"sync"
+
+	"gitlab.com/bboehmke/sunny/proto"
+)
+
+// Responder answers discovery requests received by a Connection for
+// virtual devices (e.g. simulators or gateways)
+type Responder struct {
+	conn *Connection
+
+	mutex   sync.RWMutex
+	devices map[string]net.IP
+}
+
+// NewResponder creates a responder and registers it on the connection
+func (c *Connection) NewResponder() *Responder {
+	responder := &Responder{
+		conn:    c,
+		devices: make(map[string]net.IP),
+	}
+
+	c.responderMutex.Lock()
+	c.responders = append(c.responders, responder)
+	c.responderMutex.Unlock()
+	return responder
+}
+
+// AddDevice advertises a virtual device with the given IP address
+func (r *Responder) AddDevice(ip net.IP) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.devices[ip.String()] = ip.To4()
+}
+
+// RemoveDevice stops advertising the device with the given IP address
+func (r *Responder) RemoveDevice(ip net.IP) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.devices, ip.String())
+}
+
+// Close unregisters the responder from the connection
+func (r *Responder) Close() {
+	c := r.conn
+	c.responderMutex.Lock()
+	defer c.responderMutex.Unlock()
+
+	responders := make([]*Responder, 0, len(c.responders))
+	for _, responder := range c.responders {
+		if responder != r {
+			responders = append(responders, responder)
+		}
+	}
+	c.responders = responders
+}
+
+// respond to a discovery request received from address
+func (r *Responder) respond(address *net.UDPAddr) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, ip := range r.devices {
+		err := r.conn.sendPacket(address, proto.NewDiscoveryResponse(ip))
+		if err != nil {
+			Log.Printf("failed to send discovery response for %s: %v", ip, err)
+		}
+	}
+}
+
+// handleDiscoveryRequest forwards discovery requests to all responders
+func (c *Connection) handleDiscoveryRequest(src *net.UDPAddr, packet *proto.Packet) {
+	if !packet.IsDiscoveryRequest() {
+		return
+	}
+
+	c.responderMutex.RLock()
+	defer c.responderMutex.RUnlock()
+
+	for _, responder := range c.responders {
+		responder.respond(src)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RequestError of a single request group of an inverter
+type RequestError struct {
+	Def InverterValuesDef
+	Err error
+}
+
+// Error returns the error message
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("request 0x%X 0x%X-0x%X: %v", e.Def.Object, e.Def.Start, e.Def.End, e.Err)
+}
+
+// Unwrap returns the error of the request
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// ValuesResult of GetValuesResult with the state of every request group
+// Note: energy meters send all values at once and have no request groups
+type ValuesResult struct {
+	// Values of all successful requests
+	Values map[ValueID]TimedValue
+
+	// Succeeded request groups
+	Succeeded []InverterValuesDef
+	// Unsupported request groups (device responded with status 0x15)
+	Unsupported []InverterValuesDef
+	// Failed request groups with the error of the request
+	Failed []*RequestError
+}
+
+// newValuesResult creates an empty result
+func newValuesResult() *ValuesResult {
+	return &ValuesResult{
+		Values: make(map[ValueID]TimedValue),
+	}
+}
+
+// add result of a request group
+func (r *ValuesResult) add(def InverterValuesDef, values map[ValueID]TimedValue, err error) {
+	switch {
+	case err == nil:
+		r.Succeeded = append(r.Succeeded, def)
+		for id, value := range values {
+			r.Values[id] = value
+		}
+
+	case errors.Is(err, ErrNotSupported):
+		r.Unsupported = append(r.Unsupported, def)
+
+	default:
+		r.Failed = append(r.Failed, &RequestError{
+			Def: def,
+			Err: err,
+		})
+	}
+}
+
+// Complete returns true if no request group failed
+func (r *ValuesResult) Complete() bool {
+	return len(r.Failed) == 0
+}
+
+// Err returns the first error of the failed request groups or nil
+func (r *ValuesResult) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	return r.Failed[0]
+}
@@ -0,0 +1,173 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunspec
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+	"gitlab.com/bboehmke/sunny/modbus"
+)
+
+// Device provides the values of a single SMA device
+type Device interface {
+	SerialNumber() uint32
+	IsEnergyMeter() bool
+	GetValuesCtx(ctx context.Context) (map[sunny.ValueID]interface{}, error)
+}
+
+// unit of the gateway with the last register image
+type unit struct {
+	device    Device
+	registers []uint16
+}
+
+// Gateway provides devices as Modbus units in SunSpec layout
+type Gateway struct {
+	// Timeout of a single device poll
+	Timeout time.Duration
+
+	mutex sync.RWMutex
+	units map[uint8]*unit
+}
+
+// NewGateway creates a gateway without units
+func NewGateway(timeout time.Duration) *Gateway {
+	return &Gateway{
+		Timeout: timeout,
+		units:   make(map[uint8]*unit),
+	}
+}
+
+// AddDevice as Modbus unit
+func (g *Gateway) AddDevice(unitID uint8, device Device) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if _, ok := g.units[unitID]; ok {
+		return fmt.Errorf("unit %d already in use", unitID)
+	}
+	g.units[unitID] = &unit{device: device}
+	return nil
+}
+
+// Update registers of all units
+// Note: units of devices that can not be polled respond with an exception
+func (g *Gateway) Update(ctx context.Context) error {
+	g.mutex.RLock()
+	units := make(map[uint8]Device, len(g.units))
+	for id, u := range g.units {
+		units[id] = u.device
+	}
+	g.mutex.RUnlock()
+
+	var lastErr error
+	for id, device := range units {
+		registers, err := g.poll(ctx, id, device)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to poll device %d: %w", device.SerialNumber(), err)
+		}
+
+		g.mutex.Lock()
+		g.units[id].registers = registers
+		g.mutex.Unlock()
+	}
+	return lastErr
+}
+
+// Run updates the units in the given interval until the context is done
+func (g *Gateway) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := g.Update(ctx)
+		if err != nil {
+			sunny.Log.Printf("sunspec: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll device and create register image
+func (g *Gateway) poll(ctx context.Context, unitID uint8, device Device) ([]uint16, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.Timeout)
+	defer cancel()
+
+	values, err := device.GetValuesCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	common := Common{
+		Manufacturer:  "SMA",
+		Serial:        strconv.FormatUint(uint64(device.SerialNumber()), 10),
+		DeviceAddress: uint16(unitID),
+	}
+	if version, ok := values[sunny.SoftwareVersion].(uint32); ok {
+		common.Version = formatVersion(sunny.DecodeVersion(version))
+	}
+
+	if device.IsEnergyMeter() {
+		common.Model = "Energy Meter"
+		return MeterRegisters(common, values), nil
+	}
+
+	common.Model = "Inverter"
+	if name, ok := values[sunny.DeviceName].(string); ok && name != "" {
+		common.Model = name
+	}
+	return InverterRegisters(common, values), nil
+}
+
+// ReadHoldingRegisters returns quantity registers of unit starting at address
+func (g *Gateway) ReadHoldingRegisters(unitID uint8, address, quantity uint16) ([]uint16, error) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	u, ok := g.units[unitID]
+	if !ok || u.registers == nil {
+		return nil, modbus.ErrGatewayTargetFailed
+	}
+
+	if address < BaseAddress {
+		return nil, modbus.ErrIllegalDataAddress
+	}
+	start := int(address - BaseAddress)
+	end := start + int(quantity)
+	if end > len(u.registers) {
+		return nil, modbus.ErrIllegalDataAddress
+	}
+	return append([]uint16{}, u.registers[start:end]...), nil
+}
+
+// ReadInputRegisters is not supported by SunSpec
+func (g *Gateway) ReadInputRegisters(uint8, uint16, uint16) ([]uint16, error) {
+	return nil, modbus.ErrIllegalFunction
+}
+
+// formatVersion of SMA firmware (major.minor.build)
+func formatVersion(version sunny.Version) string {
+	return fmt.Sprintf("%d.%d.%d", version.Major, version.Minor, version.Build)
+}
@@ -0,0 +1,176 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunspec
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny"
+	"gitlab.com/bboehmke/sunny/modbus"
+)
+
+// testDevice with static values
+type testDevice struct {
+	serial      uint32
+	energyMeter bool
+	values      map[sunny.ValueID]interface{}
+	err         error
+}
+
+func (d *testDevice) SerialNumber() uint32 { return d.serial }
+func (d *testDevice) IsEnergyMeter() bool  { return d.energyMeter }
+func (d *testDevice) GetValuesCtx(context.Context) (map[sunny.ValueID]interface{}, error) {
+	return d.values, d.err
+}
+
+// readString from registers
+func readString(registers []uint16) string {
+	var data []byte
+	for _, reg := range registers {
+		data = append(data, byte(reg>>8), byte(reg))
+	}
+	for len(data) > 0 && data[len(data)-1] == 0 {
+		data = data[:len(data)-1]
+	}
+	return string(data)
+}
+
+func TestGateway_Inverter(t *testing.T) {
+	ass := assert.New(t)
+
+	gateway := NewGateway(time.Second)
+	ass.NoError(gateway.AddDevice(1, &testDevice{
+		serial: 1234567890,
+		values: map[sunny.ValueID]interface{}{
+			sunny.DeviceName:        "SN: 1234567890",
+			sunny.CurrentL1:         4.321,
+			sunny.CurrentL2:         4.0,
+			sunny.CurrentL3:         4.0,
+			sunny.VoltageL1:         230.12,
+			sunny.VoltageL2:         231.0,
+			sunny.VoltageL3:         229.0,
+			sunny.ActivePowerPlus:   uint32(45000),
+			sunny.UtilityFrequency:  50.01,
+			sunny.ActiveEnergyPlus:  uint64(3600 * 12345),
+			sunny.PowerS1:           uint32(1000),
+			sunny.PowerS2:           uint32(1500),
+			sunny.VoltageS1:         400.0,
+			sunny.DeviceTemperature: 35.5,
+			sunny.DeviceStatus:      uint32(307),
+		},
+	}))
+	ass.Error(gateway.AddDevice(1, &testDevice{}))
+
+	// not polled yet
+	_, err := gateway.ReadHoldingRegisters(1, BaseAddress, 2)
+	ass.Equal(modbus.ErrGatewayTargetFailed, err)
+
+	ass.NoError(gateway.Update(context.Background()))
+
+	registers, err := gateway.ReadHoldingRegisters(1, BaseAddress, 2+2+66+2+50+2)
+	ass.NoError(err)
+	ass.Equal([]uint16{0x5375, 0x6e53}, registers[0:2])
+
+	// common model
+	common := registers[2:]
+	ass.Equal([]uint16{1, 66}, common[0:2])
+	ass.Equal("SMA", readString(common[2:18]))
+	ass.Equal("SN: 1234567890", readString(common[18:34]))
+	ass.Equal("1234567890", readString(common[50:66]))
+	ass.Equal(uint16(1), common[66])
+
+	// inverter model
+	inverter := registers[70:]
+	ass.Equal([]uint16{103, 50}, inverter[0:2])
+	data := inverter[2:]
+	ass.Equal(uint16(1232), data[0])    // A
+	ass.Equal(uint16(432), data[1])     // AphA
+	ass.Equal(uint16(0xFFFE), data[4])  // A_SF
+	ass.Equal(uint16(0xFFFF), data[5])  // PPVphAB
+	ass.Equal(uint16(2301), data[8])    // PhVphA
+	ass.Equal(uint16(4500), data[12])   // W
+	ass.Equal(uint16(1), data[13])      // W_SF
+	ass.Equal(uint16(5001), data[14])   // Hz
+	ass.Equal(uint16(0x8000), data[16]) // VA
+	ass.Equal([]uint16{0, 12345}, data[22:24])
+	ass.Equal(uint16(0xFFFF), data[25]) // DCA
+	ass.Equal(uint16(4000), data[27])   // DCV
+	ass.Equal(uint16(2500), data[29])   // DCW
+	ass.Equal(uint16(355), data[31])    // TmpCab
+	ass.Equal(uint16(4), data[36])      // St
+	ass.Equal(uint16(307), data[37])    // StVnd
+
+	// end model
+	ass.Equal([]uint16{0xFFFF, 0}, registers[122:124])
+
+	// read beyond end
+	_, err = gateway.ReadHoldingRegisters(1, BaseAddress+120, 5)
+	ass.Equal(modbus.ErrIllegalDataAddress, err)
+	_, err = gateway.ReadHoldingRegisters(1, 0, 1)
+	ass.Equal(modbus.ErrIllegalDataAddress, err)
+	_, err = gateway.ReadInputRegisters(1, BaseAddress, 1)
+	ass.Equal(modbus.ErrIllegalFunction, err)
+}
+
+func TestGateway_Meter(t *testing.T) {
+	ass := assert.New(t)
+
+	device := &testDevice{
+		serial:      42,
+		energyMeter: true,
+		values: map[sunny.ValueID]interface{}{
+			sunny.ActivePowerPlus:   0.0,
+			sunny.ActivePowerMinus:  1500.5,
+			sunny.ActivePowerPlusL1: 200.0,
+			sunny.VoltageL1:         230.0,
+			sunny.PowerFactor:       0.95,
+			sunny.ActiveEnergyPlus:  float64(3600 * 10),
+			sunny.ActiveEnergyMinus: float64(3600 * 20),
+			sunny.SoftwareVersion:   uint32(0x02001152),
+		},
+	}
+	gateway := NewGateway(time.Second)
+	ass.NoError(gateway.AddDevice(3, device))
+	ass.NoError(gateway.Update(context.Background()))
+
+	registers, err := gateway.ReadHoldingRegisters(3, BaseAddress, 2+2+66+2+105+2)
+	ass.NoError(err)
+	ass.Equal("Energy Meter", readString(registers[20:36]))
+	ass.Equal("2.0.17", readString(registers[44:52]))
+
+	meter := registers[70:]
+	ass.Equal([]uint16{201, 105}, meter[0:2])
+	data := meter[2:]
+	ass.Equal(uint16(0x8000), data[0])        // A
+	ass.Equal(uint16(2300), data[5])          // PhV
+	ass.Equal(uint16(0x10000-1501), data[16]) // W
+	ass.Equal(uint16(200), data[17])          // WphA
+	ass.Equal(uint16(0x8000), data[18])       // WphB
+	ass.Equal(uint16(950), data[31])          // PF
+	ass.Equal([]uint16{0, 20}, data[36:38])   // TotWhExp
+	ass.Equal([]uint16{0, 10}, data[44:46])   // TotWhImp
+	ass.Equal([]uint16{0xFFFF, 0}, registers[177:179])
+
+	// failed poll removes registers
+	device.err = errors.New("timeout")
+	ass.Error(gateway.Update(context.Background()))
+	_, err = gateway.ReadHoldingRegisters(3, BaseAddress, 2)
+	ass.Equal(modbus.ErrGatewayTargetFailed, err)
+}
@@ -0,0 +1,315 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sunspec maps device values to SunSpec Modbus models.
+package sunspec
+
+import (
+	"math"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// BaseAddress of the SunSpec register map (register 40001)
+const BaseAddress uint16 = 40000
+
+// SunSpec model IDs
+const (
+	ModelCommon               uint16 = 1
+	ModelInverterSinglePhase  uint16 = 101
+	ModelInverterThreePhase   uint16 = 103
+	ModelMeterSinglePhase     uint16 = 201
+	ModelMeterThreePhaseWye   uint16 = 203
+	modelEnd                  uint16 = 0xFFFF
+	modelInverterLength              = 50
+	modelMeterLength                 = 105
+	modelCommonLength                = 66
+	energyWsToWh                     = 1. / 3600
+	powerFactorToPercent             = 100.
+	scaleFactorCurrent        int16  = -2
+	scaleFactorVoltage        int16  = -1
+	scaleFactorFrequency      int16  = -2
+	scaleFactorPowerFactor    int16  = -1
+	scaleFactorTemperature    int16  = -1
+	scaleFactorEnergy         int16  = 0
+	scaleFactorPowerMinimum   int16  = 0
+	scaleFactorNotImplemented int16  = -0x8000
+)
+
+// sunSpecMarker "SunS" at the base address
+var sunSpecMarker = []uint16{0x5375, 0x6e53}
+
+// Common information of a device (SunSpec model 1)
+type Common struct {
+	Manufacturer  string
+	Model         string
+	Options       string
+	Version       string
+	Serial        string
+	DeviceAddress uint16
+}
+
+// InverterRegisters returns the SunSpec registers of an inverter starting at
+// BaseAddress with the common and inverter (101 or 103) models
+func InverterRegisters(common Common, deviceValues map[sunny.ValueID]interface{}) []uint16 {
+	return joinModels(commonModel(common), inverterModel(deviceValues))
+}
+
+// MeterRegisters returns the SunSpec registers of an energy meter starting at
+// BaseAddress with the common and meter (201 or 203) models
+func MeterRegisters(common Common, deviceValues map[sunny.ValueID]interface{}) []uint16 {
+	return joinModels(commonModel(common), meterModel(deviceValues))
+}
+
+// joinModels with SunSpec marker and end model
+func joinModels(models ...*model) []uint16 {
+	registers := append([]uint16{}, sunSpecMarker...)
+	for _, m := range models {
+		registers = append(registers, m.registers()...)
+	}
+	return append(registers, modelEnd, 0)
+}
+
+// commonModel creates model 1
+func commonModel(common Common) *model {
+	m := newModel(ModelCommon, modelCommonLength)
+	m.setString(0, 16, common.Manufacturer)
+	m.setString(16, 16, common.Model)
+	m.setString(32, 8, common.Options)
+	m.setString(40, 8, common.Version)
+	m.setString(48, 16, common.Serial)
+	m.setUint16(64, common.DeviceAddress)
+	m.setUint16(65, notImplementedInt16) // pad
+	return m
+}
+
+// inverterModel creates model 101 or 103
+func inverterModel(deviceValues map[sunny.ValueID]interface{}) *model {
+	v := values(deviceValues)
+
+	id := ModelInverterSinglePhase
+	_, hasL2 := v.float(sunny.VoltageL2)
+	if hasL2 {
+		id = ModelInverterThreePhase
+	}
+	m := newModel(id, modelInverterLength)
+
+	// current
+	current, currentOk := v.sum(sunny.CurrentL1, sunny.CurrentL2, sunny.CurrentL3)
+	m.setUint16(0, uint16Register(current, currentOk, scaleFactorCurrent))
+	m.setUint16(1, v.uint16(sunny.CurrentL1, scaleFactorCurrent))
+	m.setUint16(2, v.uint16(sunny.CurrentL2, scaleFactorCurrent))
+	m.setUint16(3, v.uint16(sunny.CurrentL3, scaleFactorCurrent))
+	m.setScaleFactor(4, scaleFactorCurrent)
+
+	// voltage
+	m.setUint16(5, notImplementedUint16)
+	m.setUint16(6, notImplementedUint16)
+	m.setUint16(7, notImplementedUint16)
+	m.setUint16(8, v.uint16(sunny.VoltageL1, scaleFactorVoltage))
+	m.setUint16(9, v.uint16(sunny.VoltageL2, scaleFactorVoltage))
+	m.setUint16(10, v.uint16(sunny.VoltageL3, scaleFactorVoltage))
+	m.setScaleFactor(11, scaleFactorVoltage)
+
+	// active power
+	power, powerOk := v.float(sunny.ActivePowerPlus)
+	sf := int16ScaleFactor(power)
+	m.setUint16(12, int16Register(power, powerOk, sf))
+	m.setScaleFactor(13, sf)
+
+	// frequency
+	m.setUint16(14, v.uint16(sunny.UtilityFrequency, scaleFactorFrequency))
+	m.setScaleFactor(15, scaleFactorFrequency)
+
+	// apparent power, reactive power and power factor
+	m.setUint16(16, notImplementedInt16)
+	m.setScaleFactor(17, scaleFactorNotImplemented)
+	m.setUint16(18, notImplementedInt16)
+	m.setScaleFactor(19, scaleFactorNotImplemented)
+	m.setUint16(20, notImplementedInt16)
+	m.setScaleFactor(21, scaleFactorNotImplemented)
+
+	// energy
+	energy, energyOk := v.float(sunny.ActiveEnergyPlus)
+	m.setAcc32(22, acc32Register(energy*energyWsToWh, energyOk, scaleFactorEnergy))
+	m.setScaleFactor(24, scaleFactorEnergy)
+
+	// DC values
+	dcCurrent, dcCurrentOk := v.sum(sunny.CurrentS1, sunny.CurrentS2)
+	m.setUint16(25, uint16Register(dcCurrent, dcCurrentOk, scaleFactorCurrent))
+	m.setScaleFactor(26, scaleFactorCurrent)
+	m.setUint16(27, v.uint16(sunny.VoltageS1, scaleFactorVoltage))
+	m.setScaleFactor(28, scaleFactorVoltage)
+	dcPower, dcPowerOk := v.sum(sunny.PowerS1, sunny.PowerS2)
+	sf = int16ScaleFactor(dcPower)
+	m.setUint16(29, int16Register(dcPower, dcPowerOk, sf))
+	m.setScaleFactor(30, sf)
+
+	// temperatures
+	m.setUint16(31, v.int16(sunny.DeviceTemperature, scaleFactorTemperature))
+	m.setUint16(32, notImplementedInt16)
+	m.setUint16(33, notImplementedInt16)
+	m.setUint16(34, notImplementedInt16)
+	m.setScaleFactor(35, scaleFactorTemperature)
+
+	// operating state
+	status, statusOk := v.float(sunny.DeviceStatus)
+	m.setUint16(36, operatingState(uint32(status), statusOk))
+	m.setUint16(37, uint16Register(status, statusOk, 0))
+
+	// events (bitfield32) -> no events
+	for i := 38; i < 50; i++ {
+		m.setUint16(i, 0)
+	}
+	return m
+}
+
+// meterModel creates model 201 or 203
+func meterModel(deviceValues map[sunny.ValueID]interface{}) *model {
+	v := values(deviceValues)
+
+	id := ModelMeterSinglePhase
+	_, hasL2 := v.float(sunny.VoltageL2)
+	if hasL2 {
+		id = ModelMeterThreePhaseWye
+	}
+	m := newModel(id, modelMeterLength)
+
+	// current
+	current, currentOk := v.sum(sunny.CurrentL1, sunny.CurrentL2, sunny.CurrentL3)
+	m.setUint16(0, int16Register(current, currentOk, scaleFactorCurrent))
+	m.setUint16(1, v.int16(sunny.CurrentL1, scaleFactorCurrent))
+	m.setUint16(2, v.int16(sunny.CurrentL2, scaleFactorCurrent))
+	m.setUint16(3, v.int16(sunny.CurrentL3, scaleFactorCurrent))
+	m.setScaleFactor(4, scaleFactorCurrent)
+
+	// voltage (line to neutral)
+	voltage, voltageOk := v.sum(sunny.VoltageL1, sunny.VoltageL2, sunny.VoltageL3)
+	phases := 1.
+	if hasL2 {
+		phases = 3
+	}
+	m.setUint16(5, int16Register(voltage/phases, voltageOk, scaleFactorVoltage))
+	m.setUint16(6, v.int16(sunny.VoltageL1, scaleFactorVoltage))
+	m.setUint16(7, v.int16(sunny.VoltageL2, scaleFactorVoltage))
+	m.setUint16(8, v.int16(sunny.VoltageL3, scaleFactorVoltage))
+	for i := 9; i < 13; i++ {
+		m.setUint16(i, notImplementedInt16)
+	}
+	m.setScaleFactor(13, scaleFactorVoltage)
+
+	// frequency
+	m.setUint16(14, v.int16(sunny.UtilityFrequency, scaleFactorFrequency))
+	m.setScaleFactor(15, scaleFactorFrequency)
+
+	// power values (positive -> import from grid)
+	setPower := func(offset int, ids [4][2]sunny.ValueID) {
+		var powers [4]float64
+		var ok [4]bool
+		for i, pair := range ids {
+			powers[i], ok[i] = v.diff(pair[0], pair[1])
+		}
+		sf := int16ScaleFactor(powers[:]...)
+		for i := range ids {
+			m.setUint16(offset+i, int16Register(powers[i], ok[i], sf))
+		}
+		m.setScaleFactor(offset+4, sf)
+	}
+	setPower(16, [4][2]sunny.ValueID{
+		{sunny.ActivePowerPlus, sunny.ActivePowerMinus},
+		{sunny.ActivePowerPlusL1, sunny.ActivePowerMinusL1},
+		{sunny.ActivePowerPlusL2, sunny.ActivePowerMinusL2},
+		{sunny.ActivePowerPlusL3, sunny.ActivePowerMinusL3},
+	})
+	setPower(21, [4][2]sunny.ValueID{
+		{sunny.ApparentPowerPlus, sunny.ApparentPowerMinus},
+		{sunny.ApparentPowerPlusL1, sunny.ApparentPowerMinusL1},
+		{sunny.ApparentPowerPlusL2, sunny.ApparentPowerMinusL2},
+		{sunny.ApparentPowerPlusL3, sunny.ApparentPowerMinusL3},
+	})
+	setPower(26, [4][2]sunny.ValueID{
+		{sunny.ReactivePowerPlus, sunny.ReactivePowerMinus},
+		{sunny.ReactivePowerPlusL1, sunny.ReactivePowerMinusL1},
+		{sunny.ReactivePowerPlusL2, sunny.ReactivePowerMinusL2},
+		{sunny.ReactivePowerPlusL3, sunny.ReactivePowerMinusL3},
+	})
+
+	// power factor in percent
+	for i, id := range []sunny.ValueID{sunny.PowerFactor, sunny.PowerFactorL1, sunny.PowerFactorL2, sunny.PowerFactorL3} {
+		pf, ok := v.float(id)
+		m.setUint16(31+i, int16Register(pf*powerFactorToPercent, ok, scaleFactorPowerFactor))
+	}
+	m.setScaleFactor(35, scaleFactorPowerFactor)
+
+	// active energy (exported and imported)
+	for i, id := range []sunny.ValueID{
+		sunny.ActiveEnergyMinus, sunny.ActiveEnergyMinusL1, sunny.ActiveEnergyMinusL2, sunny.ActiveEnergyMinusL3,
+		sunny.ActiveEnergyPlus, sunny.ActiveEnergyPlusL1, sunny.ActiveEnergyPlusL2, sunny.ActiveEnergyPlusL3,
+	} {
+		energy, ok := v.float(id)
+		m.setAcc32(36+i*2, acc32Register(energy*energyWsToWh, ok, scaleFactorEnergy))
+	}
+	m.setScaleFactor(52, scaleFactorEnergy)
+
+	// apparent energy (exported and imported)
+	for i, id := range []sunny.ValueID{
+		sunny.ApparentEnergyMinus, sunny.ApparentEnergyMinusL1, sunny.ApparentEnergyMinusL2, sunny.ApparentEnergyMinusL3,
+		sunny.ApparentEnergyPlus, sunny.ApparentEnergyPlusL1, sunny.ApparentEnergyPlusL2, sunny.ApparentEnergyPlusL3,
+	} {
+		energy, ok := v.float(id)
+		m.setAcc32(53+i*2, acc32Register(energy*energyWsToWh, ok, scaleFactorEnergy))
+	}
+	m.setScaleFactor(69, scaleFactorEnergy)
+
+	// reactive energy per quadrant is not provided by the energy meter
+	for i := 70; i < 102; i++ {
+		m.setUint16(i, 0)
+	}
+	m.setScaleFactor(102, scaleFactorNotImplemented)
+
+	// events (bitfield32) -> no events
+	m.setUint16(103, 0)
+	m.setUint16(104, 0)
+	return m
+}
+
+// int16ScaleFactor returns the smallest scale factor that fits all values in int16
+func int16ScaleFactor(values ...float64) int16 {
+	sf := scaleFactorPowerMinimum
+	for _, value := range values {
+		for math.Abs(value)/math.Pow10(int(sf)) > math.MaxInt16-1 && sf < 10 {
+			sf++
+		}
+	}
+	return sf
+}
+
+// operatingState maps the SMA device status to the SunSpec operating state
+func operatingState(status uint32, ok bool) uint16 {
+	if !ok {
+		return notImplementedUint16
+	}
+	switch status {
+	case 307: // Ok
+		return 4 // MPPT
+	case 455: // Warning
+		return 4 // MPPT
+	case 35: // Fault
+		return 7 // FAULT
+	case 303: // Off
+		return 1 // OFF
+	default:
+		return notImplementedUint16
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunspec
+
+import (
+	"math"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// values of not implemented registers
+const (
+	notImplementedInt16  uint16 = 0x8000
+	notImplementedUint16 uint16 = 0xFFFF
+	notImplementedAcc32  uint32 = 0x00000000
+)
+
+// model is a SunSpec model with ID, length and data registers
+type model struct {
+	id   uint16
+	data []uint16
+}
+
+// newModel with the given data length (all registers must be set by the caller)
+func newModel(id uint16, length int) *model {
+	m := &model{
+		id:   id,
+		data: make([]uint16, length),
+	}
+	return m
+}
+
+// registers returns header and data of model
+func (m *model) registers() []uint16 {
+	return append([]uint16{m.id, uint16(len(m.data))}, m.data...)
+}
+
+// setString at offset with the given register length
+func (m *model) setString(offset, length int, s string) {
+	data := []byte(s)
+	for i := 0; i < length; i++ {
+		var reg uint16
+		if i*2 < len(data) {
+			reg = uint16(data[i*2]) << 8
+		}
+		if i*2+1 < len(data) {
+			reg |= uint16(data[i*2+1])
+		}
+		m.data[offset+i] = reg
+	}
+}
+
+// setUint16 at offset
+func (m *model) setUint16(offset int, value uint16) {
+	m.data[offset] = value
+}
+
+// setAcc32 at offset
+func (m *model) setAcc32(offset int, value uint32) {
+	m.data[offset] = uint16(value >> 16)
+	m.data[offset+1] = uint16(value)
+}
+
+// setScaleFactor at offset
+func (m *model) setScaleFactor(offset int, sf int16) {
+	m.data[offset] = uint16(sf)
+}
+
+// values of a device with helper to scale them to registers
+type values map[sunny.ValueID]interface{}
+
+// float returns the value as float64
+func (v values) float(id sunny.ValueID) (float64, bool) {
+	switch value := v[id].(type) {
+	case float64:
+		return value, true
+	case uint64:
+		return float64(value), true
+	case uint32:
+		return float64(value), true
+	case int32:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	default:
+		return 0, false
+	}
+}
+
+// sum of all given values (false if none exists)
+func (v values) sum(ids ...sunny.ValueID) (float64, bool) {
+	var sum float64
+	found := false
+	for _, id := range ids {
+		if value, ok := v.float(id); ok {
+			sum += value
+			found = true
+		}
+	}
+	return sum, found
+}
+
+// diff of the given values (false if none exists)
+func (v values) diff(plus, minus sunny.ValueID) (float64, bool) {
+	p, okPlus := v.float(plus)
+	m, okMinus := v.float(minus)
+	return p - m, okPlus || okMinus
+}
+
+// int16 returns the value as scaled int16 register
+func (v values) int16(id sunny.ValueID, sf int16) uint16 {
+	value, ok := v.float(id)
+	return int16Register(value, ok, sf)
+}
+
+// uint16 returns the value as scaled uint16 register
+func (v values) uint16(id sunny.ValueID, sf int16) uint16 {
+	value, ok := v.float(id)
+	return uint16Register(value, ok, sf)
+}
+
+// int16Register scales the value to int16 register
+func int16Register(value float64, ok bool, sf int16) uint16 {
+	if !ok {
+		return notImplementedInt16
+	}
+	scaled := math.Round(value / math.Pow10(int(sf)))
+	if scaled > math.MaxInt16 || scaled <= math.MinInt16 {
+		return notImplementedInt16
+	}
+	return uint16(int16(scaled))
+}
+
+// uint16Register scales the value to uint16 register
+func uint16Register(value float64, ok bool, sf int16) uint16 {
+	if !ok {
+		return notImplementedUint16
+	}
+	scaled := math.Round(value / math.Pow10(int(sf)))
+	if scaled >= math.MaxUint16 || scaled < 0 {
+		return notImplementedUint16
+	}
+	return uint16(scaled)
+}
+
+// acc32Register scales the value to acc32 register
+func acc32Register(value float64, ok bool, sf int16) uint32 {
+	if !ok {
+		return notImplementedAcc32
+	}
+	scaled := math.Round(value / math.Pow10(int(sf)))
+	if scaled > math.MaxUint32 || scaled < 0 {
+		return notImplementedAcc32
+	}
+	return uint32(scaled)
+}
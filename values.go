@@ -14,7 +14,13 @@
 
 package sunny
 
-import "gitlab.com/bboehmke/sunny/proto/net2"
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gitlab.com/bboehmke/sunny/proto/net2"
+)
 
 //go:generate go run github.com/dmarkham/enumer -type ValueID -output values_enumer.go
 
@@ -184,6 +190,9 @@ const (
 	DeviceType
 	// SoftwareVersion Software version of device
 	SoftwareVersion
+
+	// ActivePowerLimit Limitation of active power (AC)
+	ActivePowerLimit
 )
 
 // ValueDescription describes a value
@@ -279,6 +288,8 @@ var valueDesc = map[ValueID]ValueDescription{
 	DeviceTemperature: {"Temperature of device", "°C", "temperature"},
 	DeviceType:        {"ID of device type", "", ""},
 	SoftwareVersion:   {"Software version of device", "", ""},
+
+	ActivePowerLimit: {"Limitation of active power (AC)", "W", "power"},
 }
 
 // GetValueDescription for value
@@ -291,6 +302,11 @@ func GetValueInfo(id ValueID) ValueDescription {
 	return valueDesc[id]
 }
 
+// GetInverterValuesDefs returns the definitions of all known inverter values
+func GetInverterValuesDefs() []InverterValuesDef {
+	return append([]InverterValuesDef{}, inverterValues...)
+}
+
 // cache for responses and requests
 var (
 	// inverterResponseValues map response codes to ValueID
@@ -383,6 +399,15 @@ var inverterValues = []InverterValuesDef{
 	{0x5800, 0x00821E00, 0x008220FF, 0x00, 0x821E, DeviceName, 0},
 	{0x5800, 0x00821E00, 0x008220FF, 0x00, 0x821F, DeviceClass, 0},
 	{0x5800, 0x00821E00, 0x008220FF, 0x00, 0x8220, DeviceType, 0},
+	{0x5800, 0x00823400, 0x008234FF, 0x00, 0x8234, SoftwareVersion, 0},
+
+	{0x5800, 0x00832A00, 0x00832AFF, 0x00, 0x832A, ActivePowerLimit, 0},
+}
+
+// inverterParameters contains values that can be written to inverters
+// with the minimum firmware version required (zero for all versions)
+var inverterParameters = map[ValueID]Version{
+	ActivePowerLimit: {},
 }
 
 // checkInverterValue checks if response is a known value
@@ -429,9 +454,58 @@ func getInverterRequests(values []InverterValuesDef) []InverterValuesDef {
 	return defs
 }
 
-// parseInverterValues from response
-func parseInverterValues(values []*net2.ResponseValue) map[ValueID]interface{} {
+// encodeInverterValue to a response value that can be written to the device
+func encodeInverterValue(def InverterValuesDef, value interface{}) (*net2.ResponseValue, error) {
+	var raw uint32
+	switch v := value.(type) {
+	case uint32:
+		raw = v
+	case int:
+		if v < 0 || uint64(v) > math.MaxUint32 {
+			return nil, fmt.Errorf("value %d out of range for %s", v, def.ID)
+		}
+		raw = uint32(v)
+	case float64:
+		// revert correction factor
+		if def.Factor != 0 {
+			v /= def.Factor
+		}
+		v = math.Round(v)
+		if math.IsNaN(v) || v < 0 || v > math.MaxUint32 {
+			return nil, fmt.Errorf("value %f out of range for %s", v, def.ID)
+		}
+		raw = uint32(v)
+	default:
+		return nil, fmt.Errorf("unsupported value type %T for %s", value, def.ID)
+	}
+
+	return &net2.ResponseValue{
+		Class:     0x01,
+		Code:      def.Code,
+		Type:      0x00,
+		Timestamp: uint32(time.Now().Unix()),
+		Values:    []interface{}{raw},
+	}, nil
+}
+
+// TimedValue is a value with the time it was measured by the device
+type TimedValue struct {
+	Value interface{}
+	Time  time.Time
+}
+
+// stripTimes from timed values
+func stripTimes(values map[ValueID]TimedValue) map[ValueID]interface{} {
 	data := make(map[ValueID]interface{}, len(values))
+	for id, value := range values {
+		data[id] = value.Value
+	}
+	return data
+}
+
+// parseInverterValues from response
+func parseInverterValues(values []*net2.ResponseValue) map[ValueID]TimedValue {
+	data := make(map[ValueID]TimedValue, len(values))
 
 	for _, val := range values {
 		if len(val.Values) == 0 {
@@ -440,6 +514,10 @@ func parseInverterValues(values []*net2.ResponseValue) map[ValueID]interface{} {
 
 		if id := checkInverterValue(val); id != 0 {
 			value := val.Values[0]
+			// firmware version is the last value of the record
+			if id == SoftwareVersion {
+				value = val.Values[len(val.Values)-1]
+			}
 			// handle correction factor
 			if inverterValueMap[id].Factor != 0 {
 				if v, ok := value.(uint64); ok {
@@ -452,7 +530,10 @@ func parseInverterValues(values []*net2.ResponseValue) map[ValueID]interface{} {
 					value = float64(v) * inverterValueMap[id].Factor
 				}
 			}
-			data[id] = value
+			data[id] = TimedValue{
+				Value: value,
+				Time:  time.Unix(int64(val.Timestamp), 0),
+			}
 		}
 	}
 	return data
@@ -534,8 +615,8 @@ var emValues = []energyMeterValuesDef{
 }
 
 // convertEnergyMeterValues from OBIS to ID based map
-func convertEnergyMeterValues(values map[string]interface{}) map[ValueID]interface{} {
-	data := make(map[ValueID]interface{}, len(values))
+func convertEnergyMeterValues(values map[string]interface{}, t time.Time) map[ValueID]TimedValue {
+	data := make(map[ValueID]TimedValue, len(values))
 	for obis, value := range values {
 		if def, ok := emObisMap[obis]; ok {
 			// handle correction factor
@@ -546,7 +627,10 @@ func convertEnergyMeterValues(values map[string]interface{}) map[ValueID]interfa
 					value = float64(v) * def.Factor
 				}
 			}
-			data[def.ID] = value
+			data[def.ID] = TimedValue{
+				Value: value,
+				Time:  t,
+			}
 		} else {
 			Log.Printf("unknown obis value received: %s", obis)
 		}
@@ -0,0 +1,63 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeInverterValue(t *testing.T) {
+	ass := assert.New(t)
+	def := getInverterRequest(ActivePowerLimit)
+
+	for _, value := range []interface{}{uint32(3000), 3000, 3000.0, 2999.6} {
+		v, err := encodeInverterValue(def, value)
+		ass.NoError(err)
+		if ass.NotNil(v) {
+			ass.Equal([]interface{}{uint32(3000)}, v.Values)
+		}
+	}
+
+	v, err := encodeInverterValue(def, float64(math.MaxUint32))
+	ass.NoError(err)
+	if ass.NotNil(v) {
+		ass.Equal([]interface{}{uint32(math.MaxUint32)}, v.Values)
+	}
+
+	for _, value := range []interface{}{
+		-1, -1.0, math.MaxUint32 + 1, float64(math.MaxUint32) + 1,
+		math.NaN(), math.Inf(1), math.Inf(-1), "3000",
+	} {
+		_, err := encodeInverterValue(def, value)
+		ass.Error(err, "%v", value)
+	}
+}
+
+func TestValueID_Stable(t *testing.T) {
+	ass := assert.New(t)
+
+	// IDs may be persisted -> new values must be added at the end
+	ass.Equal(ValueID(1), ActivePowerMax)
+	ass.Equal(ValueID(77), DeviceType)
+	ass.Equal(ValueID(78), SoftwareVersion)
+	ass.Equal(ValueID(79), ActivePowerLimit)
+
+	for i, id := range ValueIDValues() {
+		ass.Equal(ValueID(i+1), id)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"fmt"
+	"strings"
+)
+
+// release types of firmware versions ordered by maturity
+// N: no revision, E: experimental, A: alpha, B: beta, R: release, S: special
+const releaseTypes = "NEABRS"
+
+// Version of a device firmware
+type Version struct {
+	Major uint8
+	Minor uint8
+	Build uint8
+	// Release type (e.g. 'R' for release or 'B' for beta)
+	Release byte
+}
+
+// DecodeVersion of SMA packed format
+// (BCD major, BCD minor, build and release type in the lowest byte)
+func DecodeVersion(packed uint32) Version {
+	release := uint8(packed)
+	// inverters use an index, energy meters the character
+	if int(release) < len(releaseTypes) {
+		release = releaseTypes[release]
+	}
+
+	return Version{
+		Major:   decodeBCD(uint8(packed >> 24)),
+		Minor:   decodeBCD(uint8(packed >> 16)),
+		Build:   uint8(packed >> 8),
+		Release: release,
+	}
+}
+
+// decodeBCD of a single byte
+func decodeBCD(b uint8) uint8 {
+	return (b>>4)*10 + b&0x0F
+}
+
+// String returns the version in the format major.minor.build.release
+// (release is omitted if not set)
+func (v Version) String() string {
+	if v.Release == 0 {
+		return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Build)
+	}
+	if v.Release >= 'A' && v.Release <= 'Z' {
+		return fmt.Sprintf("%d.%d.%d.%c", v.Major, v.Minor, v.Build, v.Release)
+	}
+	return fmt.Sprintf("%d.%d.%d.%d", v.Major, v.Minor, v.Build, v.Release)
+}
+
+// IsZero returns true if the version is unknown
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
+// Compare versions and returns -1 if v < other, 0 if v == other
+// and 1 if v > other. The release type is compared by maturity.
+func (v Version) Compare(other Version) int {
+	a := []int{int(v.Major), int(v.Minor), int(v.Build), releaseOrder(v.Release)}
+	b := []int{int(other.Major), int(other.Minor), int(other.Build), releaseOrder(other.Release)}
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		}
+		if a[i] > b[i] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Less returns true if v is older than other
+func (v Version) Less(other Version) bool {
+	return v.Compare(other) < 0
+}
+
+// AtLeast returns true if v is the same or newer than other
+func (v Version) AtLeast(other Version) bool {
+	return v.Compare(other) >= 0
+}
+
+// releaseOrder of release type (unknown types are ordered before known ones)
+func releaseOrder(release byte) int {
+	return strings.IndexByte(releaseTypes, release)
+}
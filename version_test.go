@@ -0,0 +1,56 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeVersion(t *testing.T) {
+	ass := assert.New(t)
+
+	// energy meter (release as character)
+	ass.Equal(Version{Major: 2, Minor: 0, Build: 17, Release: 'R'}, DecodeVersion(0x02001152))
+	ass.Equal("2.0.17.R", DecodeVersion(0x02001152).String())
+
+	// inverter (release as index and BCD minor)
+	ass.Equal(Version{Major: 3, Minor: 10, Build: 5, Release: 'R'}, DecodeVersion(0x03100504))
+	ass.Equal("3.10.5.R", DecodeVersion(0x03100504).String())
+	ass.Equal("1.2.3.B", DecodeVersion(0x01020303).String())
+	ass.Equal("1.2.3.9", DecodeVersion(0x01020309).String())
+
+	ass.Equal("3.11.0", Version{Major: 3, Minor: 11}.String())
+
+	ass.True(Version{}.IsZero())
+	ass.False(DecodeVersion(0x01020303).IsZero())
+}
+
+func TestVersion_Compare(t *testing.T) {
+	ass := assert.New(t)
+
+	v := Version{Major: 2, Minor: 3, Build: 4, Release: 'R'}
+	ass.Equal(0, v.Compare(v))
+	ass.Equal(1, v.Compare(Version{Major: 1, Minor: 9, Build: 9, Release: 'R'}))
+	ass.Equal(-1, v.Compare(Version{Major: 2, Minor: 4, Build: 0, Release: 'R'}))
+	ass.Equal(-1, v.Compare(Version{Major: 2, Minor: 3, Build: 5, Release: 'B'}))
+	ass.Equal(1, v.Compare(Version{Major: 2, Minor: 3, Build: 4, Release: 'B'}))
+
+	ass.True(v.Less(Version{Major: 3}))
+	ass.True(v.AtLeast(Version{Major: 2, Minor: 3}))
+	ass.True(v.AtLeast(v))
+	ass.False(v.AtLeast(Version{Major: 2, Minor: 3, Build: 5}))
+}
@@ -0,0 +1,341 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"gitlab.com/bboehmke/sunny/proto"
+)
+
+//go:generate go run github.com/dmarkham/enumer -type WatchEventType -trimprefix Device -output watcher_enumer.go
+
+// WatchEventType of a Watcher event
+type WatchEventType int
+
+// Types of watcher events
+const (
+	// DeviceAdded a new device was discovered
+	DeviceAdded WatchEventType = iota
+	// DeviceRemoved a device did not respond for multiple discovery rounds
+	DeviceRemoved
+	// DeviceAddressChanged a known device responded from a new IP address
+	DeviceAddressChanged
+)
+
+// WatchEvent emitted by a Watcher
+// The Device of DeviceRemoved and the OldDevice of DeviceAddressChanged are
+// no longer managed by the watcher and have to be closed by the consumer.
+type WatchEvent struct {
+	Type   WatchEventType
+	Device *Device
+	// OldDevice instance with the previous address (only for DeviceAddressChanged)
+	OldDevice *Device
+	// OldAddress of device (only for DeviceAddressChanged)
+	OldAddress net.IP
+}
+
+// watchedDevice entry of watcher registry
+type watchedDevice struct {
+	device *Device
+	missed int
+}
+
+// Watcher discovers devices periodically and keeps a registry of all
+// currently reachable devices
+type Watcher struct {
+	conn     *Connection
+	password string
+
+	// Interval between two discovery rounds
+	Interval time.Duration
+	// RoundDuration of a single discovery round
+	RoundDuration time.Duration
+	// MaxMissed discovery rounds before a device is removed
+	MaxMissed int
+
+	events chan WatchEvent
+
+	mutex   sync.RWMutex
+	devices map[uint32]*watchedDevice
+}
+
+// NewWatcher creates a watcher for devices of this connection
+func (c *Connection) NewWatcher(password string) *Watcher {
+	return &Watcher{
+		conn:          c,
+		password:      password,
+		Interval:      time.Minute,
+		RoundDuration: time.Second * 3,
+		MaxMissed:     3,
+		events:        make(chan WatchEvent, 16),
+		devices:       make(map[uint32]*watchedDevice),
+	}
+}
+
+// Events of the watcher
+// Note: the events must be consumed, otherwise the watcher blocks
+func (w *Watcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+// Device with the given serial number or nil if unknown
+func (w *Watcher) Device(serial uint32) *Device {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	if entry, ok := w.devices[serial]; ok {
+		return entry.device
+	}
+	return nil
+}
+
+// Devices currently known ordered by serial number
+func (w *Watcher) Devices() []*Device {
+	w.mutex.RLock()
+	devices := make([]*Device, 0, len(w.devices))
+	for _, entry := range w.devices {
+		devices = append(devices, entry.device)
+	}
+	w.mutex.RUnlock()
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].SerialNumber() < devices[j].SerialNumber()
+	})
+	return devices
+}
+
+// Run discovery rounds until the context is done
+// All devices of the registry are closed and the events channel is closed
+// on return. Invalid Interval or MaxMissed values are replaced by defaults.
+func (w *Watcher) Run(ctx context.Context) {
+	defer close(w.events)
+	defer w.closeAll()
+
+	if w.Interval <= 0 {
+		w.Interval = time.Minute
+	}
+	if w.MaxMissed < 1 {
+		w.MaxMissed = 3
+	}
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		roundCtx, cancel := context.WithTimeout(ctx, w.RoundDuration)
+		addresses := w.conn.discoverAddresses(roundCtx)
+		cancel()
+
+		// round interrupted -> results incomplete
+		if ctx.Err() != nil {
+			return
+		}
+		w.update(ctx, addresses)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// update registry with the addresses of a discovery round
+func (w *Watcher) update(ctx context.Context, addresses map[string]discoveryResult) {
+	found := w.identify(addresses)
+	foundAddresses := make(map[uint32]string, len(found))
+	for serial, device := range found {
+		foundAddresses[serial] = device.Address().IP.String()
+	}
+
+	var events []WatchEvent
+	var duplicates []*Device
+	w.mutex.Lock()
+	knownAddresses := make(map[uint32]string, len(w.devices))
+	for serial, entry := range w.devices {
+		knownAddresses[serial] = entry.device.Address().IP.String()
+	}
+
+	diff := diffWatched(knownAddresses, foundAddresses)
+	for _, serial := range diff.added {
+		w.devices[serial] = &watchedDevice{device: found[serial]}
+		events = append(events, WatchEvent{
+			Type:   DeviceAdded,
+			Device: found[serial],
+		})
+	}
+	for _, serial := range diff.changed {
+		old := w.devices[serial].device
+		w.devices[serial] = &watchedDevice{device: found[serial]}
+		events = append(events, WatchEvent{
+			Type:       DeviceAddressChanged,
+			Device:     found[serial],
+			OldDevice:  old,
+			OldAddress: old.Address().IP,
+		})
+	}
+	for _, serial := range diff.unchanged {
+		// keep known instance
+		w.devices[serial].missed = 0
+		duplicates = append(duplicates, found[serial])
+	}
+	for _, serial := range diff.missing {
+		entry := w.devices[serial]
+		entry.missed++
+		if entry.missed >= w.MaxMissed {
+			delete(w.devices, serial)
+			events = append(events, WatchEvent{
+				Type:   DeviceRemoved,
+				Device: entry.device,
+			})
+		}
+	}
+	w.mutex.Unlock()
+
+	for _, device := range duplicates {
+		device.Close()
+	}
+	for _, event := range events {
+		w.emit(ctx, event)
+	}
+}
+
+// identify the devices of a discovery round by serial number
+// Note: IP addresses are not stable (e.g. swapped by DHCP) -> every address
+// is pinged to get the serial number of the device
+func (w *Watcher) identify(addresses map[string]discoveryResult) map[uint32]*Device {
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	found := make(map[uint32]*Device, len(addresses))
+	for address, result := range addresses {
+		wg.Add(1)
+		go func(address string, result discoveryResult) {
+			defer wg.Done()
+
+			device, err := w.conn.newDiscoveredDevice(result, w.password)
+			if err != nil {
+				Log.Printf("watcher - skip ip %s: %v", address, err)
+				return
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if other, ok := found[device.SerialNumber()]; ok {
+				// same device responded from multiple addresses
+				other.Close()
+			}
+			found[device.SerialNumber()] = device
+		}(address, result)
+	}
+	wg.Wait()
+	return found
+}
+
+// watchDiff of the registry and a discovery round by serial number
+type watchDiff struct {
+	added     []uint32
+	changed   []uint32
+	unchanged []uint32
+	missing   []uint32
+}
+
+// diffWatched compares the addresses of known devices with the addresses
+// found in a discovery round (both by serial number)
+func diffWatched(known, found map[uint32]string) watchDiff {
+	var diff watchDiff
+	for serial, address := range found {
+		knownAddress, ok := known[serial]
+		switch {
+		case !ok:
+			diff.added = append(diff.added, serial)
+		case knownAddress != address:
+			diff.changed = append(diff.changed, serial)
+		default:
+			diff.unchanged = append(diff.unchanged, serial)
+		}
+	}
+	for serial := range known {
+		if _, ok := found[serial]; !ok {
+			diff.missing = append(diff.missing, serial)
+		}
+	}
+
+	for _, serials := range [][]uint32{diff.added, diff.changed, diff.unchanged, diff.missing} {
+		sort.Slice(serials, func(i, j int) bool {
+			return serials[i] < serials[j]
+		})
+	}
+	return diff
+}
+
+// emit event unless the context is done
+func (w *Watcher) emit(ctx context.Context, event WatchEvent) {
+	Log.Printf("watcher - %s: %d at %s", event.Type, event.Device.SerialNumber(), event.Device.Address().IP)
+	select {
+	case w.events <- event:
+	case <-ctx.Done():
+	}
+}
+
+// closeAll devices of registry
+func (w *Watcher) closeAll() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for serial, entry := range w.devices {
+		entry.device.Close()
+		delete(w.devices, serial)
+	}
+}
+
+// discoverAddresses sends discovery requests until the context is done and
+// returns all responding devices by IP address
+func (c *Connection) discoverAddresses(ctx context.Context) map[string]discoveryResult {
+	addresses := make(map[string]discoveryResult)
+	ticker := time.NewTicker(time.Millisecond * 500)
+	defer ticker.Stop()
+
+	discoverCh := make(chan discoveryResult, 10)
+	c.registerDiscoverer(discoverCh)
+	defer c.unregisterDiscoverer(discoverCh)
+
+	c.sendDiscoveryRequest()
+	for {
+		select {
+		case <-ctx.Done():
+			return addresses
+
+		case result := <-discoverCh:
+			addresses[result.ip] = result
+
+		case <-ticker.C:
+			c.sendDiscoveryRequest()
+		}
+	}
+}
+
+// sendDiscoveryRequest to the multicast address
+func (c *Connection) sendDiscoveryRequest() {
+	Log.Printf("send discover package")
+	err := c.write(proto.NewDiscoveryRequest().Bytes(), c.address)
+	if err != nil {
+		Log.Printf("failed to send packet: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+// Code generated by "enumer -type WatchEventType -trimprefix Device -output watcher_enumer.go"; DO NOT EDIT.
+
+package sunny
+
+import (
+	"fmt"
+	"strings"
+)
+
+const _WatchEventTypeName = "AddedRemovedAddressChanged"
+
+var _WatchEventTypeIndex = [...]uint8{0, 5, 12, 26}
+
+const _WatchEventTypeLowerName = "addedremovedaddresschanged"
+
+func (i WatchEventType) String() string {
+	if i < 0 || i >= WatchEventType(len(_WatchEventTypeIndex)-1) {
+		return fmt.Sprintf("WatchEventType(%d)", i)
+	}
+	return _WatchEventTypeName[_WatchEventTypeIndex[i]:_WatchEventTypeIndex[i+1]]
+}
+
+// An "invalid array index" compiler error signifies that the constant values have changed.
+// Re-run the stringer command to generate them again.
+func _WatchEventTypeNoOp() {
+	var x [1]struct{}
+	_ = x[DeviceAdded-(0)]
+	_ = x[DeviceRemoved-(1)]
+	_ = x[DeviceAddressChanged-(2)]
+}
+
+var _WatchEventTypeValues = []WatchEventType{DeviceAdded, DeviceRemoved, DeviceAddressChanged}
+
+var _WatchEventTypeNameToValueMap = map[string]WatchEventType{
+	_WatchEventTypeName[0:5]:   DeviceAdded,
+	_WatchEventTypeName[5:12]:  DeviceRemoved,
+	_WatchEventTypeName[12:26]: DeviceAddressChanged,
+}
+
+var _WatchEventTypeLowerNameToValueMap = map[string]WatchEventType{
+	_WatchEventTypeLowerName[0:5]:   DeviceAdded,
+	_WatchEventTypeLowerName[5:12]:  DeviceRemoved,
+	_WatchEventTypeLowerName[12:26]: DeviceAddressChanged,
+}
+
+var _WatchEventTypeNames = []string{
+	_WatchEventTypeName[0:5],
+	_WatchEventTypeName[5:12],
+	_WatchEventTypeName[12:26],
+}
+
+// WatchEventTypeString retrieves an enum value from the enum constants string name.
+// Throws an error if the param is not part of the enum.
+func WatchEventTypeString(s string) (WatchEventType, error) {
+	if val, ok := _WatchEventTypeNameToValueMap[s]; ok {
+		return val, nil
+	}
+
+	if val, ok := _WatchEventTypeLowerNameToValueMap[strings.ToLower(s)]; ok {
+		return val, nil
+	}
+	return 0, fmt.Errorf("%s does not belong to WatchEventType values", s)
+}
+
+// WatchEventTypeValues returns all values of the enum
+func WatchEventTypeValues() []WatchEventType {
+	return _WatchEventTypeValues
+}
+
+// WatchEventTypeStrings returns a slice of all String values of the enum
+func WatchEventTypeStrings() []string {
+	strs := make([]string, len(_WatchEventTypeNames))
+	copy(strs, _WatchEventTypeNames)
+	return strs
+}
+
+// IsAWatchEventType returns "true" if the value is listed in the enum definition. "false" otherwise
+func (i WatchEventType) IsAWatchEventType() bool {
+	for _, v := range _WatchEventTypeValues {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sunny
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny/proto"
+	"gitlab.com/bboehmke/sunny/proto/net2"
+)
+
+func TestDiffWatched(t *testing.T) {
+	ass := assert.New(t)
+
+	tests := []struct {
+		name  string
+		known map[uint32]string
+		found map[uint32]string
+		diff  watchDiff
+	}{
+		{"empty", nil, nil, watchDiff{}},
+		{"added",
+			map[uint32]string{1: "10.0.0.1"},
+			map[uint32]string{1: "10.0.0.1", 3: "10.0.0.3", 2: "10.0.0.2"},
+			watchDiff{added: []uint32{2, 3}, unchanged: []uint32{1}}},
+		{"missing",
+			map[uint32]string{1: "10.0.0.1", 2: "10.0.0.2"},
+			map[uint32]string{2: "10.0.0.2"},
+			watchDiff{unchanged: []uint32{2}, missing: []uint32{1}}},
+		{"address changed",
+			map[uint32]string{1: "10.0.0.1"},
+			map[uint32]string{1: "10.0.0.5"},
+			watchDiff{changed: []uint32{1}}},
+		{"addresses swapped",
+			map[uint32]string{1: "10.0.0.1", 2: "10.0.0.2"},
+			map[uint32]string{1: "10.0.0.2", 2: "10.0.0.1"},
+			watchDiff{changed: []uint32{1, 2}}},
+		{"address taken over",
+			map[uint32]string{1: "10.0.0.1"},
+			map[uint32]string{2: "10.0.0.1"},
+			watchDiff{added: []uint32{2}, missing: []uint32{1}}},
+	}
+	for _, test := range tests {
+		ass.Equal(test.diff, diffWatched(test.known, test.found), test.name)
+	}
+}
+
+// meterResult of an energy meter broadcast
+func meterResult(ip string, serial uint32) discoveryResult {
+	return discoveryResult{
+		ip:          ip,
+		energyMeter: true,
+		id:          net2.DeviceId{SusyID: 270, SerialNumber: serial},
+	}
+}
+
+func TestWatcher_Update(t *testing.T) {
+	ass := assert.New(t)
+
+	// energy meters are identified by their broadcasts -> no network required
+	conn := &Connection{
+		policy:           DefaultPolicy,
+		receiverChannels: make(map[string][]chan *proto.Packet),
+	}
+	watcher := conn.NewWatcher("0000")
+	watcher.MaxMissed = 2
+	defer watcher.closeAll()
+
+	events := func() map[uint32]WatchEvent {
+		result := make(map[uint32]WatchEvent)
+		for len(watcher.events) > 0 {
+			event := <-watcher.events
+			result[event.Device.SerialNumber()] = event
+		}
+		return result
+	}
+	ctx := context.Background()
+
+	watcher.update(ctx, map[string]discoveryResult{
+		"10.0.0.1": meterResult("10.0.0.1", 1),
+		"10.0.0.2": meterResult("10.0.0.2", 2),
+	})
+	added := events()
+	ass.Len(added, 2)
+	ass.Equal(DeviceAdded, added[1].Type)
+	ass.Equal(DeviceAdded, added[2].Type)
+
+	// unchanged -> no events and same instance
+	device := watcher.Device(1)
+	watcher.update(ctx, map[string]discoveryResult{
+		"10.0.0.1": meterResult("10.0.0.1", 1),
+		"10.0.0.2": meterResult("10.0.0.2", 2),
+	})
+	ass.Empty(events())
+	ass.Equal(device, watcher.Device(1))
+
+	// addresses swapped (e.g. DHCP) -> changed for both devices
+	watcher.update(ctx, map[string]discoveryResult{
+		"10.0.0.1": meterResult("10.0.0.1", 2),
+		"10.0.0.2": meterResult("10.0.0.2", 1),
+	})
+	changed := events()
+	ass.Len(changed, 2)
+	for serial, address := range map[uint32]string{1: "10.0.0.2", 2: "10.0.0.1"} {
+		ass.Equal(DeviceAddressChanged, changed[serial].Type)
+		ass.Equal(address, changed[serial].Device.Address().IP.String())
+		ass.Equal(address, watcher.Device(serial).Address().IP.String())
+	}
+	ass.Equal("10.0.0.1", changed[1].OldAddress.String())
+	ass.Equal("10.0.0.2", changed[2].OldAddress.String())
+	ass.Equal(device, changed[1].OldDevice)
+
+	// old instance is still usable until closed by the consumer
+	ass.True(usable(changed[1].OldDevice))
+	ass.True(usable(changed[2].OldDevice))
+	changed[1].OldDevice.Close()
+	changed[2].OldDevice.Close()
+
+	// device 2 is removed after MaxMissed rounds
+	watcher.update(ctx, map[string]discoveryResult{
+		"10.0.0.2": meterResult("10.0.0.2", 1),
+	})
+	ass.Empty(events())
+	watcher.update(ctx, map[string]discoveryResult{
+		"10.0.0.2": meterResult("10.0.0.2", 1),
+	})
+	removed := events()
+	ass.Len(removed, 1)
+	ass.Equal(DeviceRemoved, removed[2].Type)
+	ass.Nil(watcher.Device(2))
+	ass.Len(watcher.Devices(), 1)
+
+	// removed device is still usable until closed by the consumer
+	ass.True(usable(removed[2].Device))
+	ass.Len(conn.receiverChannels["10.0.0.1"], 1)
+	removed[2].Device.Close()
+
+	// closed devices are unregistered from the connection
+	ass.Len(conn.receiverChannels["10.0.0.2"], 1)
+	ass.Empty(conn.receiverChannels["10.0.0.1"])
+}
+
+// usable returns true if the device was not closed
+func usable(device *Device) bool {
+	select {
+	case <-device.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func TestWatcher_Run(t *testing.T) {
+	ass := assert.New(t)
+
+	conn := &Connection{
+		policy:           DefaultPolicy,
+		receiverChannels: make(map[string][]chan *proto.Packet),
+		socket:           &discardTransport{closed: make(chan struct{})},
+	}
+	watcher := conn.NewWatcher("0000")
+	watcher.RoundDuration = time.Millisecond * 10
+	// invalid values -> defaults
+	watcher.Interval = 0
+	watcher.MaxMissed = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range watcher.Events() {
+		}
+	}()
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		cancel()
+	}()
+	watcher.Run(ctx)
+
+	// events channel is closed on return
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		ass.Fail("events channel not closed")
+	}
+	ass.Equal(time.Minute, watcher.Interval)
+	ass.Equal(3, watcher.MaxMissed)
+}
@@ -0,0 +1,245 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package webconnect implements the HTTPS JSON API (Webconnect) of SMA
+// inverters as alternative to Speedwire.
+package webconnect
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrSessionExpired is returned if the session of the client is no longer valid
+var ErrSessionExpired = errors.New("webconnect: session expired")
+
+// errCodeSession is returned by the inverter for invalid or expired sessions
+const errCodeSession = 401
+
+// Client for the Webconnect API of a single inverter
+type Client struct {
+	// URL of the inverter (e.g. https://192.168.1.10)
+	URL string
+	// Right of the user ("usr" or "istl")
+	Right string
+	// Password of the user
+	Password string
+	// HTTPClient used for requests
+	HTTPClient *http.Client
+
+	mutex sync.Mutex
+	sid   string
+}
+
+// NewClient for the inverter at address (host or URL)
+// Note: certificate validation is disabled because inverters use self-signed certificates
+func NewClient(address, password string) *Client {
+	if !strings.Contains(address, "://") {
+		address = "https://" + address
+	}
+	return &Client{
+		URL:      strings.TrimRight(address, "/"),
+		Right:    "usr",
+		Password: password,
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: true,
+				},
+			},
+		},
+	}
+}
+
+// Login to the inverter and store the session ID
+func (c *Client) Login(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.login(ctx)
+}
+
+// Logout from the inverter
+func (c *Client) Logout(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.sid == "" {
+		return nil
+	}
+	err := c.post(ctx, "/dyn/logout.json", struct{}{}, nil)
+	c.sid = ""
+	return err
+}
+
+// GetValues for the given keys (e.g. 6100_40263F00)
+// The result contains the values per key in the order of the channels (e.g. DC inputs).
+// Values are not scaled and are float64, string, uint32 (status tags) or nil.
+func (c *Client) GetValues(ctx context.Context, keys ...string) (map[string][]interface{}, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	request := struct {
+		DestDev []string `json:"destDev"`
+		Keys    []string `json:"keys"`
+	}{[]string{}, keys}
+
+	var result map[string]map[string]map[string][]struct {
+		Val json.RawMessage `json:"val"`
+	}
+
+	// login on first request and retry once with new session if expired
+	for i := 0; i < 2; i++ {
+		if c.sid == "" {
+			err := c.login(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err := c.post(ctx, "/dyn/getValues.json", request, &result)
+		if errors.Is(err, ErrSessionExpired) {
+			c.sid = ""
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[string][]interface{})
+		for _, device := range result {
+			for key, groups := range device {
+				groupKeys := make([]string, 0, len(groups))
+				for group := range groups {
+					groupKeys = append(groupKeys, group)
+				}
+				sort.Strings(groupKeys)
+
+				for _, group := range groupKeys {
+					for _, entry := range groups[group] {
+						values[key] = append(values[key], parseValue(entry.Val))
+					}
+				}
+			}
+		}
+		return values, nil
+	}
+	return nil, ErrSessionExpired
+}
+
+// login and store session ID (mutex must be locked)
+func (c *Client) login(ctx context.Context) error {
+	c.sid = ""
+
+	var result struct {
+		SID string `json:"sid"`
+	}
+	err := c.post(ctx, "/dyn/login.json", map[string]string{
+		"right": c.Right,
+		"pass":  c.Password,
+	}, &result)
+	if errors.Is(err, ErrSessionExpired) {
+		return fmt.Errorf("webconnect: login failed: invalid password")
+	}
+	if err != nil {
+		return fmt.Errorf("webconnect: login failed: %w", err)
+	}
+	if result.SID == "" {
+		return fmt.Errorf("webconnect: login failed: no session ID received")
+	}
+
+	c.sid = result.SID
+	return nil
+}
+
+// post request to path and decode result of response
+func (c *Client) post(ctx context.Context, path string, request, result interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	u := c.URL + path
+	if c.sid != "" {
+		u += "?sid=" + url.QueryEscape(c.sid)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webconnect: unexpected status %s", resp.Status)
+	}
+
+	var response struct {
+		Err    interface{}     `json:"err"`
+		Result json.RawMessage `json:"result"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		return fmt.Errorf("webconnect: invalid response: %w", err)
+	}
+
+	if response.Err != nil {
+		if code, ok := response.Err.(float64); ok && code == errCodeSession {
+			return ErrSessionExpired
+		}
+		return fmt.Errorf("webconnect: error %v", response.Err)
+	}
+	if result == nil || len(response.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// parseValue of a single value entry
+func parseValue(data json.RawMessage) interface{} {
+	var value interface{}
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return nil
+	}
+
+	switch v := value.(type) {
+	case float64, string:
+		return v
+	case []interface{}:
+		// status values are a list of tags
+		if len(v) == 0 {
+			return nil
+		}
+		if tag, ok := v[0].(map[string]interface{}); ok {
+			if t, ok := tag["tag"].(float64); ok {
+				return uint32(t)
+			}
+		}
+	}
+	return nil
+}
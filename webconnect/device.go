@@ -0,0 +1,249 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webconnect
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// web key data types
+const (
+	typeUnsigned uint8 = 0x00
+	typeSigned   uint8 = 0x40
+	typeStatus   uint8 = 0x08
+	typeString   uint8 = 0x10
+)
+
+// valueTypes contains the data type of values in the web key
+// (values without type are unsigned)
+var valueTypes = map[sunny.ValueID]uint8{
+	sunny.ActivePowerPlus:    typeSigned,
+	sunny.ActivePowerPlusL1:  typeSigned,
+	sunny.ActivePowerPlusL2:  typeSigned,
+	sunny.ActivePowerPlusL3:  typeSigned,
+	sunny.CurrentL1:          typeSigned,
+	sunny.CurrentL2:          typeSigned,
+	sunny.CurrentL3:          typeSigned,
+	sunny.BatteryTemperature: typeSigned,
+	sunny.DeviceTemperature:  typeSigned,
+	sunny.PowerS1:            typeSigned,
+	sunny.PowerS2:            typeSigned,
+	sunny.VoltageS1:          typeSigned,
+	sunny.VoltageS2:          typeSigned,
+	sunny.CurrentS1:          typeSigned,
+	sunny.CurrentS2:          typeSigned,
+	sunny.DeviceStatus:       typeStatus,
+	sunny.DeviceGridRelay:    typeStatus,
+	sunny.DeviceClass:        typeStatus,
+	sunny.DeviceType:         typeStatus,
+	sunny.DeviceName:         typeString,
+}
+
+// keySerialNumber of the inverter (Nameplate.SerNum)
+const keySerialNumber = "6800_00A21E00"
+
+// objectCounters contains 64 bit counter values
+const objectCounters = 0x5400
+
+// Key returns the web key of a inverter value definition
+// (object + 0x1000, data type and code)
+func Key(def sunny.InverterValuesDef) string {
+	return fmt.Sprintf("%04X_%02X%04X00", def.Object+0x1000, valueTypes[def.ID], def.Code)
+}
+
+// valueKey maps a value to its web key and the channel index
+type valueKey struct {
+	Key     string
+	Channel int
+	Def     sunny.InverterValuesDef
+}
+
+// valueKeys of all known inverter values
+var valueKeys = func() map[sunny.ValueID]valueKey {
+	keys := make(map[sunny.ValueID]valueKey)
+	for _, def := range sunny.GetInverterValuesDefs() {
+		channel := 0
+		if def.Class > 0 {
+			channel = int(def.Class) - 1
+		}
+		keys[def.ID] = valueKey{
+			Key:     Key(def),
+			Channel: channel,
+			Def:     def,
+		}
+	}
+	return keys
+}()
+
+// convertValue to the type and unit provided by Speedwire
+func convertValue(def sunny.InverterValuesDef, value interface{}) interface{} {
+	v, ok := value.(float64)
+	if !ok {
+		return value
+	}
+
+	if def.Factor != 0 {
+		return v * def.Factor
+	}
+	if v < 0 {
+		v = 0 // values without factor are unsigned on Speedwire
+	}
+	if def.Object == objectCounters {
+		return uint64(math.Round(v))
+	}
+	return uint32(math.Round(v))
+}
+
+// Device is a SMA inverter that is read via Webconnect
+type Device struct {
+	client *Client
+	serial uint32
+}
+
+// NewDevice logs in to the inverter at address and reads the serial number
+func NewDevice(address, password string) (*Device, error) {
+	device := Device{
+		client: NewClient(address, password),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	serial, err := device.readSerialNumber(ctx, address)
+	if err != nil {
+		// release session of the implicit login
+		device.Close()
+		return nil, err
+	}
+	device.serial = serial
+
+	sunny.Log.Printf("new webconnect inverter at %s - Serial=%d", address, device.serial)
+	return &device, nil
+}
+
+// readSerialNumber from the inverter
+func (d *Device) readSerialNumber(ctx context.Context, address string) (uint32, error) {
+	values, err := d.client.GetValues(ctx, keySerialNumber)
+	if err != nil {
+		return 0, err
+	}
+	if len(values[keySerialNumber]) == 0 {
+		return 0, fmt.Errorf("webconnect: no serial number received from %s", address)
+	}
+	serial, ok := values[keySerialNumber][0].(float64)
+	if !ok {
+		return 0, fmt.Errorf("webconnect: invalid serial number received from %s", address)
+	}
+	return uint32(serial), nil
+}
+
+// Client of the device
+func (d *Device) Client() *Client {
+	return d.client
+}
+
+// Close session of device
+func (d *Device) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+	_ = d.client.Logout(ctx)
+}
+
+// SerialNumber of device
+func (d *Device) SerialNumber() uint32 {
+	return d.serial
+}
+
+// IsEnergyMeter returns always false (only inverters provide Webconnect)
+func (d *Device) IsEnergyMeter() bool {
+	return false
+}
+
+// GetValueCtx from device and returns nil if value does not exist
+// If the value is not provided via Webconnect sunny.ErrNotSupported is returned.
+func (d *Device) GetValueCtx(ctx context.Context, id sunny.ValueID) (interface{}, error) {
+	if _, ok := valueKeys[id]; !ok {
+		return nil, fmt.Errorf("%w: value %s", sunny.ErrNotSupported, id)
+	}
+
+	values, err := d.getValues(ctx, []sunny.ValueID{id})
+	if err != nil {
+		return nil, err
+	}
+	return values[id].Value, nil
+}
+
+// GetValuesCtx returns all values from device
+func (d *Device) GetValuesCtx(ctx context.Context) (map[sunny.ValueID]interface{}, error) {
+	values, err := d.GetTimedValuesCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[sunny.ValueID]interface{}, len(values))
+	for id, value := range values {
+		data[id] = value.Value
+	}
+	return data, nil
+}
+
+// GetTimedValuesCtx returns all values from device with the time of the request
+func (d *Device) GetTimedValuesCtx(ctx context.Context) (map[sunny.ValueID]sunny.TimedValue, error) {
+	ids := make([]sunny.ValueID, 0, len(valueKeys))
+	for id := range valueKeys {
+		ids = append(ids, id)
+	}
+	return d.getValues(ctx, ids)
+}
+
+// getValues of the given IDs
+func (d *Device) getValues(ctx context.Context, ids []sunny.ValueID) (map[sunny.ValueID]sunny.TimedValue, error) {
+	keys := make([]string, 0, len(ids))
+	known := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		key := valueKeys[id].Key
+		if !known[key] {
+			known[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	response, err := d.client.GetValues(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+	t := time.Now()
+
+	values := make(map[sunny.ValueID]sunny.TimedValue, len(ids))
+	for _, id := range ids {
+		key := valueKeys[id]
+		channels := response[key.Key]
+		if key.Channel >= len(channels) || channels[key.Channel] == nil {
+			continue
+		}
+		values[id] = sunny.TimedValue{
+			Value: convertValue(key.Def, channels[key.Channel]),
+			Time:  t,
+		}
+	}
+	return values, nil
+}
+
+// ensure Device implements sunny.ValueReader
+var _ sunny.ValueReader = (*Device)(nil)
@@ -0,0 +1,239 @@
+// Copyright 2019 Benjamin Böhmke <benjamin@boehmke.net>.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webconnect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"gitlab.com/bboehmke/sunny"
+)
+
+// testInverter is a stand-in for the Webconnect API of an inverter
+type testInverter struct {
+	mutex    sync.Mutex
+	password string
+	sessions map[string]bool
+	logins   int
+	values   map[string]string // key -> JSON of channel list
+}
+
+func newTestInverter() *testInverter {
+	return &testInverter{
+		password: "secret",
+		sessions: make(map[string]bool),
+		values: map[string]string{
+			keySerialNumber: `[{"val":1234567890}]`,
+			"6100_40263F00": `[{"val":4200}]`,
+			"6100_00464800": `[{"val":23012}]`,
+			"6100_00464900": `[{"val":null}]`,
+			"6380_40251E00": `[{"val":2000},{"val":2200}]`,
+			"6400_00260100": `[{"val":12345}]`,
+			"6180_08214800": `[{"val":[{"tag":307}]}]`,
+			"6800_10821E00": `[{"val":"SN: 1234567890"}]`,
+		},
+	}
+}
+
+func (i *testInverter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/dyn/login.json":
+		var request map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		if request["right"] != "usr" || request["pass"] != i.password {
+			fmt.Fprint(w, `{"err":401}`)
+			return
+		}
+		i.logins++
+		sid := fmt.Sprintf("session%d", i.logins)
+		i.sessions[sid] = true
+		fmt.Fprintf(w, `{"result":{"sid":"%s"}}`, sid)
+
+	case "/dyn/logout.json":
+		delete(i.sessions, r.URL.Query().Get("sid"))
+		fmt.Fprint(w, `{"result":{"isLogin":false}}`)
+
+	case "/dyn/getValues.json":
+		if !i.sessions[r.URL.Query().Get("sid")] {
+			fmt.Fprint(w, `{"err":401}`)
+			return
+		}
+		var request struct {
+			Keys []string `json:"keys"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+
+		result := make(map[string]json.RawMessage)
+		for _, key := range request.Keys {
+			if value, ok := i.values[key]; ok {
+				result[key] = json.RawMessage(`{"1":` + value + `}`)
+			}
+		}
+		data, _ := json.Marshal(map[string]interface{}{
+			"result": map[string]interface{}{"0199-xxxxx245": result},
+		})
+		_, _ = w.Write(data)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// expireSessions of all clients
+func (i *testInverter) expireSessions() {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.sessions = make(map[string]bool)
+}
+
+func TestKey(t *testing.T) {
+	ass := assert.New(t)
+
+	ass.Equal("6100_40263F00", valueKeys[sunny.ActivePowerPlus].Key)
+	ass.Equal("6100_00464800", valueKeys[sunny.VoltageL1].Key)
+	ass.Equal("6380_40251E00", valueKeys[sunny.PowerS2].Key)
+	ass.Equal(1, valueKeys[sunny.PowerS2].Channel)
+	ass.Equal("6400_00260100", valueKeys[sunny.ActiveEnergyPlus].Key)
+	ass.Equal("6180_08214800", valueKeys[sunny.DeviceStatus].Key)
+	ass.Equal("6800_10821E00", valueKeys[sunny.DeviceName].Key)
+	ass.Equal("6800_00832A00", valueKeys[sunny.ActivePowerLimit].Key)
+}
+
+func TestClient(t *testing.T) {
+	ass := assert.New(t)
+
+	inverter := newTestInverter()
+	server := httptest.NewTLSServer(inverter)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	// invalid password
+	client := NewClient(server.URL, "wrong")
+	_, err := client.GetValues(ctx, "6100_40263F00")
+	ass.Error(err)
+
+	client = NewClient(server.URL, "secret")
+	values, err := client.GetValues(ctx, "6100_40263F00", "6380_40251E00", "6100_00464900", "unknown")
+	ass.NoError(err)
+	ass.Equal(map[string][]interface{}{
+		"6100_40263F00": {4200.},
+		"6380_40251E00": {2000., 2200.},
+		"6100_00464900": {nil},
+	}, values)
+	ass.Equal(1, inverter.logins)
+
+	// session is reused
+	_, err = client.GetValues(ctx, "6100_40263F00")
+	ass.NoError(err)
+	ass.Equal(1, inverter.logins)
+
+	// expired session -> login again
+	inverter.expireSessions()
+	values, err = client.GetValues(ctx, "6100_40263F00")
+	ass.NoError(err)
+	ass.Equal([]interface{}{4200.}, values["6100_40263F00"])
+	ass.Equal(2, inverter.logins)
+
+	ass.NoError(client.Logout(ctx))
+	ass.Empty(inverter.sessions)
+}
+
+func TestNewDevice_InvalidSerial(t *testing.T) {
+	ass := assert.New(t)
+
+	inverter := newTestInverter()
+	inverter.values[keySerialNumber] = `[{"val":"invalid"}]`
+	server := httptest.NewTLSServer(inverter)
+	defer server.Close()
+
+	_, err := NewDevice(server.URL, "secret")
+	ass.Error(err)
+
+	// session of implicit login is released
+	ass.Equal(1, inverter.logins)
+	ass.Empty(inverter.sessions)
+
+	delete(inverter.values, keySerialNumber)
+	_, err = NewDevice(server.URL, "secret")
+	ass.Error(err)
+	ass.Equal(2, inverter.logins)
+	ass.Empty(inverter.sessions)
+}
+
+func TestDevice(t *testing.T) {
+	ass := assert.New(t)
+
+	inverter := newTestInverter()
+	server := httptest.NewTLSServer(inverter)
+	defer server.Close()
+
+	device, err := NewDevice(server.URL, "secret")
+	ass.NoError(err)
+	ass.Equal(uint32(1234567890), device.SerialNumber())
+	ass.False(device.IsEnergyMeter())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	values, err := device.GetValuesCtx(ctx)
+	ass.NoError(err)
+	ass.Equal(map[sunny.ValueID]interface{}{
+		sunny.ActivePowerPlus:  uint32(4200),
+		sunny.VoltageL1:        230.12,
+		sunny.PowerS1:          uint32(2000),
+		sunny.PowerS2:          uint32(2200),
+		sunny.ActiveEnergyPlus: float64(12345 * 3600),
+		sunny.DeviceStatus:     uint32(307),
+		sunny.DeviceName:       "SN: 1234567890",
+	}, values)
+
+	value, err := device.GetValueCtx(ctx, sunny.PowerS2)
+	ass.NoError(err)
+	ass.Equal(uint32(2200), value)
+
+	value, err = device.GetValueCtx(ctx, sunny.VoltageL2)
+	ass.NoError(err)
+	ass.Nil(value)
+
+	// energy meter value
+	value, err = device.GetValueCtx(ctx, sunny.ReactiveEnergyPlusL1)
+	ass.True(errors.Is(err, sunny.ErrNotSupported))
+	ass.Nil(value)
+
+	device.Close()
+	ass.Empty(inverter.sessions)
+
+	_, err = NewDevice(server.URL, "wrong")
+	ass.Error(err)
+}